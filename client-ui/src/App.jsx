@@ -375,6 +375,16 @@ function App() {
   const handlePieceMove = async (from, to) => {
     let fromSquare = parseSquareIndex(from)
     let toSquare = parseSquareIndex(to)
+    // ask for the promotion piece when a pawn reaches the last rank
+    let piece = board.squares[from].piece
+    if (piece && piece.type.toLowerCase() === 'p' && (to < 8 || to >= 56)) {
+      let promotion = window.prompt("Promote to (q, r, b, n)?", "q")
+      if (!promotion) {
+        return
+      }
+      sendJsonMessage({type: "movePiece", data: fromSquare + "," + toSquare + "," + promotion.trim().toLowerCase()})
+      return
+    }
     sendJsonMessage({type: "movePiece", data: fromSquare + "," + toSquare})
   }
 
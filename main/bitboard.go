@@ -0,0 +1,261 @@
+package main
+
+import (
+	"math/bits"
+)
+
+// Bitboard representation of a position.
+// Every square is mapped to a bit: square = X*8 + Y,
+// so a1 is bit 0, h1 is bit 7 and h8 is bit 63.
+
+type Bitboard uint64
+
+// Piece types in the order they are indexed in Position.Pieces.
+const pieceKinds = "PNBRQK"
+
+// Position keeps one bitboard per player and piece type.
+// It is kept in sync with Board.Locations by the Board methods.
+type Position struct {
+	// Pieces[player-1][kind], kind is the index of the type in pieceKinds.
+	Pieces [2][6]Bitboard
+
+	// All the pieces of each player.
+	Occupied [2]Bitboard
+}
+
+// Directions used by the sliding pieces.
+// The first four directions increase the square index, the last four decrease it.
+const (
+	dirNorth = iota
+	dirEast
+	dirNorthEast
+	dirNorthWest
+	dirSouth
+	dirWest
+	dirSouthEast
+	dirSouthWest
+)
+
+var dirOffsets = [8]Location{
+	{1, 0}, {0, 1}, {1, 1}, {1, -1},
+	{-1, 0}, {0, -1}, {-1, 1}, {-1, -1},
+}
+
+// The dark squares of the board, a1 is dark.
+const darkSquares Bitboard = 0xAA55AA55AA55AA55
+
+// Precomputed attack tables.
+var (
+	knightAttacks [64]Bitboard
+	kingAttacks   [64]Bitboard
+	pawnAttacks   [2][64]Bitboard // indexed by player-1
+	rayAttacks    [8][64]Bitboard // empty board rays, indexed by direction
+
+	// Squares strictly between two squares on the same line, empty if they are not aligned.
+	betweenSquares [64][64]Bitboard
+)
+
+func init() {
+	knightJumps := []Location{{1, 2}, {1, -2}, {-1, 2}, {-1, -2}, {2, 1}, {2, -1}, {-2, 1}, {-2, -1}}
+
+	for sq := 0; sq < 64; sq++ {
+		l := SquareLocation(sq)
+
+		for _, d := range knightJumps {
+			knightAttacks[sq] |= bitAt(l.X+d.X, l.Y+d.Y)
+		}
+		for _, d := range dirOffsets {
+			kingAttacks[sq] |= bitAt(l.X+d.X, l.Y+d.Y)
+		}
+		pawnAttacks[0][sq] = bitAt(l.X+1, l.Y-1) | bitAt(l.X+1, l.Y+1)
+		pawnAttacks[1][sq] = bitAt(l.X-1, l.Y-1) | bitAt(l.X-1, l.Y+1)
+
+		for dir, d := range dirOffsets {
+			for i := 1; i < 8; i++ {
+				ray := bitAt(l.X+d.X*i, l.Y+d.Y*i)
+				if ray == 0 {
+					break
+				}
+				betweenSquares[sq][SquareOf(Location{l.X + d.X*i, l.Y + d.Y*i})] = rayAttacks[dir][sq]
+				rayAttacks[dir][sq] |= ray
+			}
+		}
+	}
+}
+
+// Get the bit for a square, or an empty bitboard when it is off the board.
+func bitAt(x, y int) Bitboard {
+	if x < 0 || x > 7 || y < 0 || y > 7 {
+		return 0
+	}
+	return Bitboard(1) << uint(x*8+y)
+}
+
+// Get the square index of a location.
+func SquareOf(l Location) int {
+	return l.X*8 + l.Y
+}
+
+// Get the location of a square index.
+func SquareLocation(sq int) Location {
+	return Location{sq / 8, sq % 8}
+}
+
+// Check if the square is in the bitboard.
+func (bb Bitboard) Has(sq int) bool {
+	return bb&(Bitboard(1)<<uint(sq)) != 0
+}
+
+// Number of squares in the bitboard.
+func (bb Bitboard) Count() int {
+	return bits.OnesCount64(uint64(bb))
+}
+
+// Remove and return the lowest square of the bitboard.
+func (bb *Bitboard) PopLSB() int {
+	sq := bits.TrailingZeros64(uint64(*bb))
+	*bb &= *bb - 1
+	return sq
+}
+
+// Get the index of a piece type in pieceKinds.
+func pieceKind(pieceType rune) int {
+	switch pieceType {
+	case 'P':
+		return 0
+	case 'N':
+		return 1
+	case 'B':
+		return 2
+	case 'R':
+		return 3
+	case 'Q':
+		return 4
+	case 'K':
+		return 5
+	}
+	return -1
+}
+
+// Attacks of a slider in one direction, stopping at the first blocker.
+func slidingAttacks(dir int, sq int, occupied Bitboard) Bitboard {
+	attacks := rayAttacks[dir][sq]
+	blockers := attacks & occupied
+	if blockers == 0 {
+		return attacks
+	}
+	var blocker int
+	if dir < dirSouth {
+		blocker = bits.TrailingZeros64(uint64(blockers))
+	} else {
+		blocker = 63 - bits.LeadingZeros64(uint64(blockers))
+	}
+	return attacks ^ rayAttacks[dir][blocker]
+}
+
+// Attacks of a rook on the given square.
+func rookAttacks(sq int, occupied Bitboard) Bitboard {
+	return slidingAttacks(dirNorth, sq, occupied) |
+		slidingAttacks(dirEast, sq, occupied) |
+		slidingAttacks(dirSouth, sq, occupied) |
+		slidingAttacks(dirWest, sq, occupied)
+}
+
+// Attacks of a bishop on the given square.
+func bishopAttacks(sq int, occupied Bitboard) Bitboard {
+	return slidingAttacks(dirNorthEast, sq, occupied) |
+		slidingAttacks(dirNorthWest, sq, occupied) |
+		slidingAttacks(dirSouthEast, sq, occupied) |
+		slidingAttacks(dirSouthWest, sq, occupied)
+}
+
+// Squares attacked by a piece of the given type and player standing on sq.
+func pieceAttacks(pieceType rune, player int, sq int, occupied Bitboard) Bitboard {
+	switch pieceType {
+	case 'P':
+		return pawnAttacks[player-1][sq]
+	case 'N':
+		return knightAttacks[sq]
+	case 'B':
+		return bishopAttacks(sq, occupied)
+	case 'R':
+		return rookAttacks(sq, occupied)
+	case 'Q':
+		return bishopAttacks(sq, occupied) | rookAttacks(sq, occupied)
+	case 'K':
+		return kingAttacks[sq]
+	}
+	return 0
+}
+
+// All the occupied squares.
+func (p *Position) All() Bitboard {
+	return p.Occupied[0] | p.Occupied[1]
+}
+
+// Get the bitboard of a player's pieces of the given type.
+func (p *Position) PieceBitboard(player int, pieceType rune) Bitboard {
+	return p.Pieces[player-1][pieceKind(pieceType)]
+}
+
+// Put a piece on an empty square.
+func (p *Position) Put(player int, pieceType rune, sq int) {
+	bit := Bitboard(1) << uint(sq)
+	p.Pieces[player-1][pieceKind(pieceType)] |= bit
+	p.Occupied[player-1] |= bit
+}
+
+// Remove a piece from a square.
+func (p *Position) Remove(player int, pieceType rune, sq int) {
+	bit := Bitboard(1) << uint(sq)
+	p.Pieces[player-1][pieceKind(pieceType)] &^= bit
+	p.Occupied[player-1] &^= bit
+}
+
+// Get the player and piece type on a square.
+// Returns (0, ' ') for an empty square.
+func (p *Position) PieceAt(sq int) (int, rune) {
+	for player := 1; player <= 2; player++ {
+		if !p.Occupied[player-1].Has(sq) {
+			continue
+		}
+		for kind, pieceType := range pieceKinds {
+			if p.Pieces[player-1][kind].Has(sq) {
+				return player, pieceType
+			}
+		}
+	}
+	return 0, ' '
+}
+
+// Get the square of a player's king, or -1 if there is none.
+func (p *Position) KingSquare(player int) int {
+	kings := p.Pieces[player-1][5]
+	if kings == 0 {
+		return -1
+	}
+	return bits.TrailingZeros64(uint64(kings))
+}
+
+// Get all the pieces of the given player attacking a square.
+func (p *Position) AttackersOf(sq int, byPlayer int) Bitboard {
+	return p.attackersWith(sq, byPlayer, p.All())
+}
+
+// Get all the pieces of the given player attacking a square,
+// with the sliders blocked by the given occupancy instead of the current one.
+func (p *Position) attackersWith(sq int, byPlayer int, occupied Bitboard) Bitboard {
+	pieces := &p.Pieces[byPlayer-1]
+	// A pawn of byPlayer attacks sq if a pawn of the other player on sq would attack it.
+	attackers := pawnAttacks[2-byPlayer][sq] & pieces[0]
+	attackers |= knightAttacks[sq] & pieces[1]
+	attackers |= kingAttacks[sq] & pieces[5]
+	attackers |= bishopAttacks(sq, occupied) & (pieces[2] | pieces[4])
+	attackers |= rookAttacks(sq, occupied) & (pieces[3] | pieces[4])
+	return attackers
+}
+
+// Check if a square is attacked by the given player.
+func (p *Position) IsAttacked(sq int, byPlayer int) bool {
+	return p.AttackersOf(sq, byPlayer) != 0
+}
@@ -13,15 +13,17 @@ type Board struct {
 	// The board is represented as a 2D slice of Pieces.
 	Locations [][]Piece
 
+	// The same pieces as bitboards, used for fast move generation.
+	// Kept in sync with Locations, see setPiece() and syncPosition().
+	Position Position
+
 	// Keep track of the last move.
 	LastMove Move
 
 	// Keep track of whose turn is it
 	// 1 - white player's turn
 	// 2 - black player's turn
-	// 3 - white player won
-	// 4 - black player won
-	// 5 - draw
+	// The result of a game is kept by Game, see GameResult.
 	State int
 
 	// Castling rights
@@ -30,7 +32,17 @@ type Board struct {
 	BlackQueenSideCastle bool
 	BlackKingSideCastle  bool
 
+	// Files of the rooks the castling rights belong to, indexed by player-1.
+	// Always 7 and 0 in classical chess, see castling.go.
+	KingSideRookFile  [2]int
+	QueenSideRookFile [2]int
+
+	// Whether the game is Chess960 (Fischer Random Chess)
+	Chess960 bool
+
 	// En passant target square
+	// The square behind a pawn that just moved two squares.
+	// Location{0, 0} when there is none, a1 can never be a target.
 	EnPassantTargetSquare Location
 
 	// Halfmove clock
@@ -38,6 +50,10 @@ type Board struct {
 
 	// Fullmove number
 	FullmoveNumber int
+
+	// Zobrist hash of the position, see zobrist.go.
+	// Updated incrementally by MakeMove.
+	Hash uint64
 }
 
 type Location struct {
@@ -75,6 +91,9 @@ func (b *Board) Init() {
 	b.WhiteQueenSideCastle = true
 	b.BlackKingSideCastle = true
 	b.BlackQueenSideCastle = true
+	b.KingSideRookFile = [2]int{7, 7}
+	b.QueenSideRookFile = [2]int{0, 0}
+	b.Chess960 = false
 
 	b.HalfmoveClock = 0
 	b.FullmoveNumber = 1
@@ -85,13 +104,7 @@ func (b *Board) Init() {
 	}
 
 	// Initialize the board with empty pieces.
-	b.Locations = make([][]Piece, b.Height)
-	for i := 0; i < b.Height; i++ {
-		b.Locations[i] = make([]Piece, b.Width)
-		for j := 0; j < b.Width; j++ {
-			b.Locations[i][j] = EmptyPiece{}
-		}
-	}
+	b.clearLocations()
 	// Initialize the pieces.
 	// White pieces.
 	b.Locations[0][0] = PlayerPiece{1, 'R', Location{0, 0}}
@@ -117,9 +130,13 @@ func (b *Board) Init() {
 	for i := 0; i < b.Width; i++ {
 		b.Locations[6][i] = PlayerPiece{2, 'P', Location{6, i}}
 	}
+
+	b.syncPosition()
+	b.Hash = b.ComputeHash()
 }
 
 // decode FEN string and return a board based on the string
+// The string is not validated, use ParseFEN() for input from users.
 func InitFEN(fen string) Board {
 	var b Board
 	b.Height = 8
@@ -131,13 +148,7 @@ func InitFEN(fen string) Board {
 	}
 
 	// Initialize the board with empty pieces.
-	b.Locations = make([][]Piece, b.Height)
-	for i := 0; i < b.Height; i++ {
-		b.Locations[i] = make([]Piece, b.Width)
-		for j := 0; j < b.Width; j++ {
-			b.Locations[i][j] = EmptyPiece{}
-		}
-	}
+	b.clearLocations()
 
 	// Decode FEN string
 	// FEN string is in the format:
@@ -176,11 +187,9 @@ func InitFEN(fen string) Board {
 	}
 
 	// Castling availability
+	// Also accepts X-FEN and Shredder-FEN for Chess960.
 	castlingAvailability := fenParts[2]
-	b.WhiteKingSideCastle = strings.Contains(castlingAvailability, "K")
-	b.WhiteQueenSideCastle = strings.Contains(castlingAvailability, "Q")
-	b.BlackKingSideCastle = strings.Contains(castlingAvailability, "k")
-	b.BlackQueenSideCastle = strings.Contains(castlingAvailability, "q")
+	b.setCastlingRights(castlingAvailability)
 
 	// En passant target square
 	enPassantTargetSquare := fenParts[3]
@@ -196,9 +205,23 @@ func InitFEN(fen string) Board {
 	fullmoveNumber := fenParts[5]
 	b.FullmoveNumber, _ = strconv.Atoi(fullmoveNumber)
 
+	b.syncPosition()
+	b.Hash = b.ComputeHash()
+
 	return b
 }
 
+// Allocate the locations and fill them with empty pieces.
+func (b *Board) clearLocations() {
+	b.Locations = make([][]Piece, b.Height)
+	for i := 0; i < b.Height; i++ {
+		b.Locations[i] = make([]Piece, b.Width)
+		for j := 0; j < b.Width; j++ {
+			b.Locations[i][j] = EmptyPiece{}
+		}
+	}
+}
+
 // Print the board.
 func (b *Board) Print() {
 	for i := b.Height - 1; i >= 0; i-- {
@@ -235,10 +258,7 @@ func (b *Board) PrintWithBorder() {
 
 // Deep copy the board.
 func (b *Board) Copy() Board {
-	newBoard := Board{
-		Width:  b.Width,
-		Height: b.Height,
-	}
+	newBoard := *b
 	newBoard.Locations = make([][]Piece, b.Height)
 	for i := 0; i < b.Height; i++ {
 		newBoard.Locations[i] = make([]Piece, b.Width)
@@ -249,6 +269,33 @@ func (b *Board) Copy() Board {
 	return newBoard
 }
 
+// Rebuild the bitboards from Locations.
+func (b *Board) syncPosition() {
+	b.Position = Position{}
+	for i := 0; i < b.Height; i++ {
+		for j := 0; j < b.Width; j++ {
+			piece := b.Locations[i][j]
+			if !piece.IsEmpty() {
+				b.Position.Put(piece.GetPlayer(), piece.GetType(), SquareOf(Location{i, j}))
+			}
+		}
+	}
+}
+
+// Set the piece at the given location, keeping the bitboards and the hash in sync.
+func (b *Board) setPiece(l Location, piece Piece) {
+	old := b.Locations[l.X][l.Y]
+	if !old.IsEmpty() {
+		b.Position.Remove(old.GetPlayer(), old.GetType(), SquareOf(l))
+		b.Hash ^= pieceHash(old.GetPlayer(), old.GetType(), SquareOf(l))
+	}
+	if !piece.IsEmpty() {
+		b.Position.Put(piece.GetPlayer(), piece.GetType(), SquareOf(l))
+		b.Hash ^= pieceHash(piece.GetPlayer(), piece.GetType(), SquareOf(l))
+	}
+	b.Locations[l.X][l.Y] = piece
+}
+
 // Get the piece at the given location.
 func (b *Board) GetPiece(x, y int) Piece {
 	// Check if the location is out of bound.
@@ -278,99 +325,130 @@ func (b *Board) GetPlayerPieces(player int) []Piece {
 // Get all moves for the given player.
 // This function does not check if the player is in check.
 func (b *Board) GetPlayerMoves(player int) []Move {
-	pieces := b.GetPlayerPieces(player)
-	moves := make([]Move, 0)
-	for _, piece := range pieces {
-		moves = append(moves, piece.GetMoves(b)...)
-	}
-	return moves
-}
-
-// Get all moves from the given player except the king
-// since the king cannot attack another king
-// while this avoid to have an infinite loop for detecting moves
-// HACK: This is a hack, we should find a better way to do this.
-func (b *Board) GetPlayerMovesExceptKing(player int) []Move {
-	pieces := b.GetPlayerPieces(player)
-	moves := make([]Move, 0)
-	for _, piece := range pieces {
-		if piece.GetType() != 'K' {
-			moves = append(moves, piece.GetMoves(b)...)
-		}
-	}
-	return moves
+	return b.generateMoves(player)
 }
 
 // Get all the legal moves for the given player.
-// This function uses GetPlayerMoves() and filters out moves that put the player in check.
+// The moves are generated directly from the checks and pins of the position, see legalmoves.go.
 func (b *Board) GetPlayerLegalMoves(player int) []Move {
-	moves := b.GetPlayerMoves(player)
-	legalMoves := make([]Move, 0)
+	return b.generateLegalMoves(player)
+}
 
-	// get the enemy king location
-	// Since we do not calculate the king moves for CheckPlayerInCheck()
-	// we need to get the enemy king location to check if the move is
-	enemyKingLoc := Location{}
-	for i := 0; i < b.Height; i++ {
-		for j := 0; j < b.Width; j++ {
-			if b.Locations[i][j].GetType() == 'K' && b.Locations[i][j].GetPlayer() != player {
-				enemyKingLoc = Location{i, j}
-			}
+// Get all the legal moves for the given player by trying every move.
+// This function uses GetPlayerMoves() and filters out moves that put the player in check.
+// It is much slower than GetPlayerLegalMoves and only kept to verify it.
+func (b *Board) GetPlayerLegalMovesByTrial(player int) []Move {
+	moves := b.GetPlayerMoves(player)
+	legalMoves := make([]Move, 0, len(moves))
+	for _, move := range moves {
+		undo := b.MakeMove(move)
+		if !b.CheckPlayerInCheck(player) {
+			legalMoves = append(legalMoves, move)
 		}
+		b.UnmakeMove(undo)
 	}
+	return legalMoves
+}
 
-	for _, move := range moves {
-		newBoard := b.Copy()
-		newBoard.MakeMove(move)
-		piece := newBoard.GetPieceAtLocation(move.To)
-		if !newBoard.CheckPlayerInCheck(player) {
-			if piece.GetType() != 'K' {
-				legalMoves = append(legalMoves, move)
-			}
-			if piece.GetType() == 'K' && move.To.Distance(enemyKingLoc) > 1 {
-				legalMoves = append(legalMoves, move)
-			}
-		}
+// Check if a square is attacked by any piece of the given player, the king included.
+// The square itself may be empty or hold a piece of either player.
+func (b *Board) IsSquareAttacked(loc Location, byPlayer int) bool {
+	return b.Position.IsAttacked(SquareOf(loc), byPlayer)
+}
 
+// Get all the pieces of the given player attacking a square, the king included.
+func (b *Board) Attackers(loc Location, byPlayer int) []Piece {
+	attackers := b.Position.AttackersOf(SquareOf(loc), byPlayer)
+	pieces := make([]Piece, 0, attackers.Count())
+	for attackers != 0 {
+		from := SquareLocation(attackers.PopLSB())
+		pieces = append(pieces, b.GetPieceAtLocation(from))
 	}
-	return legalMoves
+	return pieces
 }
 
 // Disambiguate given a set of moves.
-// When multiple same type of pieces can move to the same location
-// TODO: Implement this function.
+// When multiple same type of pieces can move to the same location,
+// IsDisambiguation is set on their moves so ToString writes the from square.
+// Pawn moves and castling are left alone.
 func DisambiguateMoves(moves []Move) {
-	// Check if there are multiple same type of pieces that can move to the same location.
-	// If so, disambiguate the moves.
-
+	for i := range moves {
+		if !isPieceMove(moves[i]) {
+			continue
+		}
+		for j := range moves {
+			if i != j && isPieceMove(moves[j]) && moves[j].Piece == moves[i].Piece &&
+				moves[j].To == moves[i].To && moves[j].From != moves[i].From {
+				moves[i].IsDisambiguation = true
+				break
+			}
+		}
+	}
 }
 
 // Check if the given player is in check.
 func (b *Board) CheckPlayerInCheck(player int) bool {
-	// Get the king's location.
-	kingLoc := Location{}
-	for i := 0; i < b.Height; i++ {
-		for j := 0; j < b.Width; j++ {
-			if b.Locations[i][j].GetType() == 'K' && b.Locations[i][j].GetPlayer() == player {
-				kingLoc = Location{i, j}
-			}
-		}
-	}
-	// Check if any of the opponent's pieces can move to the king's location.
-	opponentMoves := b.GetPlayerMovesExceptKing(3 - player)
-	for _, move := range opponentMoves {
-		if move.To == kingLoc {
-			return true
-		}
+	kingSq := b.Position.KingSquare(player)
+	if kingSq < 0 {
+		return false
 	}
-	return false
+	// Check if any of the opponent's pieces attacks the king's location.
+	return b.IsSquareAttacked(SquareLocation(kingSq), 3-player)
+}
+
+// Information needed by UnmakeMove to take back a move.
+type UndoInfo struct {
+	// The move that was made and the player who made it.
+	Move   Move
+	Player int
+
+	// The piece captured by the move, EmptyPiece{} if there is none.
+	Captured Piece
+
+	// Board state before the move.
+	LastMove              Move
+	State                 int
+	WhiteQueenSideCastle  bool
+	WhiteKingSideCastle   bool
+	BlackQueenSideCastle  bool
+	BlackKingSideCastle   bool
+	EnPassantTargetSquare Location
+	HalfmoveClock         int
+	FullmoveNumber        int
+	Hash                  uint64
 }
 
 // Move a piece on the board.
-func (b *Board) MakeMove(m Move) {
+// Returns the information needed to take the move back with UnmakeMove.
+func (b *Board) MakeMove(m Move) UndoInfo {
 
 	player := b.Locations[m.From.X][m.From.Y].GetPlayer()
 
+	undo := UndoInfo{
+		Move:                  m,
+		Player:                player,
+		Captured:              EmptyPiece{},
+		LastMove:              b.LastMove,
+		State:                 b.State,
+		WhiteQueenSideCastle:  b.WhiteQueenSideCastle,
+		WhiteKingSideCastle:   b.WhiteKingSideCastle,
+		BlackQueenSideCastle:  b.BlackQueenSideCastle,
+		BlackKingSideCastle:   b.BlackKingSideCastle,
+		EnPassantTargetSquare: b.EnPassantTargetSquare,
+		HalfmoveClock:         b.HalfmoveClock,
+		FullmoveNumber:        b.FullmoveNumber,
+		Hash:                  b.Hash,
+	}
+	switch m.Type {
+	case 'E':
+		undo.Captured = b.Locations[m.From.X][m.To.Y]
+	case 'M', 'C', 'P':
+		undo.Captured = b.Locations[m.To.X][m.To.Y]
+	}
+
+	// Take the old side to move, castling rights and en passant file out of the hash.
+	b.Hash ^= b.stateHash()
+
 	// Change board state
 	if player == 1 {
 		b.State = 2
@@ -382,7 +460,7 @@ func (b *Board) MakeMove(m Move) {
 	b.LastMove = m
 
 	// Update half move clock
-	if m.Piece == 'P' || m.Type == 'C' {
+	if m.Piece == 'P' || m.Type == 'C' || m.Type == 'E' || m.Type == 'P' {
 		b.HalfmoveClock = 0
 	} else {
 		b.HalfmoveClock++
@@ -393,6 +471,13 @@ func (b *Board) MakeMove(m Move) {
 		b.FullmoveNumber++
 	}
 
+	// Update the en passant target square
+	if m.Type == 'M' && m.Piece == 'P' && (m.To.X-m.From.X == 2 || m.From.X-m.To.X == 2) {
+		b.EnPassantTargetSquare = Location{(m.From.X + m.To.X) / 2, m.From.Y}
+	} else {
+		b.EnPassantTargetSquare = Location{}
+	}
+
 	// Update the castling rights
 	if m.Piece == 'K' {
 		if player == 1 {
@@ -403,58 +488,88 @@ func (b *Board) MakeMove(m Move) {
 			b.BlackKingSideCastle = false
 		}
 	}
-	if m.Piece == 'R' {
-		if player == 1 {
-			if m.From.Y == 0 && m.From.X == 0 {
-				b.WhiteQueenSideCastle = false
-			}
-			if m.From.Y == 7 && m.From.X == 0 {
-				b.WhiteKingSideCastle = false
-			}
-		} else {
-			if m.From.Y == 0 && m.From.X == 7 {
-				b.BlackQueenSideCastle = false
-			}
-			if m.From.Y == 7 && m.From.X == 7 {
-				b.BlackKingSideCastle = false
-			}
-		}
-	}
+	// A move from or to a rook's home square takes away the castling right of that rook,
+	// either the rook moved or it was captured.
+	b.clearCastlingRight(m.From)
+	b.clearCastlingRight(m.To)
+
+	b.movePieces(m, player)
 
+	// Put the new state back into the hash.
+	b.Hash ^= b.stateHash()
+
+	return undo
+}
+
+// Move the pieces of a move on the board.
+func (b *Board) movePieces(m Move, player int) {
 	// Special case for castling.
-	if m.Type == 'K' && m.From.Y-m.To.Y == 2 {
-		// Long castling.
-		b.Locations[m.From.X][m.From.Y] = EmptyPiece{}
-		b.Locations[m.From.X][0] = EmptyPiece{}
-		b.Locations[m.To.X][m.To.Y] = PlayerPiece{player, 'K', m.To}
-		b.Locations[m.To.X][m.To.Y+1] = PlayerPiece{player, 'R', Location{m.To.X, m.To.Y + 1}}
-		return
-	}
-	if m.Type == 'K' && m.From.Y-m.To.Y == -2 {
-		// Short castling.
-		b.Locations[m.From.X][m.From.Y] = EmptyPiece{}
-		b.Locations[m.From.X][7] = EmptyPiece{}
-		b.Locations[m.To.X][m.To.Y] = PlayerPiece{player, 'K', m.To}
-		b.Locations[m.To.X][m.To.Y-1] = PlayerPiece{player, 'R', Location{m.To.X, m.To.Y - 1}}
+	// Both pieces are taken off first, in Chess960 they may land on each other's squares.
+	if m.Type == 'K' {
+		rookFrom, rookTo := b.castlingRookLocation(m), castlingRookTarget(m)
+		b.setPiece(m.From, EmptyPiece{})
+		b.setPiece(rookFrom, EmptyPiece{})
+		b.setPiece(m.To, PlayerPiece{player, 'K', m.To})
+		b.setPiece(rookTo, PlayerPiece{player, 'R', rookTo})
 		return
 	}
 
 	// Special case for en passant.
 	if m.Type == 'E' {
-		b.Locations[m.From.X][m.From.Y] = EmptyPiece{}
-		b.Locations[m.From.X][m.To.Y] = EmptyPiece{}
-		b.Locations[m.To.X][m.To.Y] = PlayerPiece{player, 'P', m.To}
+		b.setPiece(m.From, EmptyPiece{})
+		b.setPiece(Location{m.From.X, m.To.Y}, EmptyPiece{})
+		b.setPiece(m.To, PlayerPiece{player, 'P', m.To})
 		return
 	}
 
 	// Default case of moving / promoting / capturing.
 	if m.Type == 'M' || m.Type == 'P' || m.Type == 'C' {
-		b.Locations[m.From.X][m.From.Y] = EmptyPiece{}
-		b.Locations[m.To.X][m.To.Y] = PlayerPiece{player, m.Piece, m.To}
-		return
+		b.setPiece(m.From, EmptyPiece{})
+		b.setPiece(m.To, PlayerPiece{player, m.Piece, m.To})
 	}
 }
 
+// Take back a move made by MakeMove.
+// The board is restored exactly to the state before the move.
+func (b *Board) UnmakeMove(undo UndoInfo) {
+	m := undo.Move
+	player := undo.Player
+
+	switch {
+	case m.Type == 'K':
+		// Put the king and the rook back on their squares.
+		rookFrom, rookTo := b.castlingRookLocation(m), castlingRookTarget(m)
+		b.setPiece(m.To, EmptyPiece{})
+		b.setPiece(rookTo, EmptyPiece{})
+		b.setPiece(m.From, PlayerPiece{player, 'K', m.From})
+		b.setPiece(rookFrom, PlayerPiece{player, 'R', rookFrom})
+	case m.Type == 'E':
+		captured := Location{m.From.X, m.To.Y}
+		b.setPiece(m.To, EmptyPiece{})
+		b.setPiece(captured, undo.Captured)
+		b.setPiece(m.From, PlayerPiece{player, 'P', m.From})
+	default:
+		// A promoted piece turns back into a pawn.
+		pieceType := m.Piece
+		if m.Type == 'P' {
+			pieceType = 'P'
+		}
+		b.setPiece(m.To, undo.Captured)
+		b.setPiece(m.From, PlayerPiece{player, pieceType, m.From})
+	}
+
+	b.LastMove = undo.LastMove
+	b.State = undo.State
+	b.WhiteQueenSideCastle = undo.WhiteQueenSideCastle
+	b.WhiteKingSideCastle = undo.WhiteKingSideCastle
+	b.BlackQueenSideCastle = undo.BlackQueenSideCastle
+	b.BlackKingSideCastle = undo.BlackKingSideCastle
+	b.EnPassantTargetSquare = undo.EnPassantTargetSquare
+	b.HalfmoveClock = undo.HalfmoveClock
+	b.FullmoveNumber = undo.FullmoveNumber
+	b.Hash = undo.Hash
+}
+
 // Check if the given player is in checkmate.
 func (b *Board) CheckPlayerInCheckmate(player int) bool {
 	if !b.CheckPlayerInCheck(player) {
@@ -482,12 +597,9 @@ func (b *Board) CheckPlayerInStalemate(player int) bool {
 // Get the piece value for a player
 func (b *Board) GetPieceValue(player int) int {
 	value := 0
-	for i := 0; i < b.Height; i++ {
-		for j := 0; j < b.Width; j++ {
-			if b.Locations[i][j].GetPlayer() == player {
-				value += b.Locations[i][j].GetValue()
-			}
-		}
+	for kind, pieceType := range pieceKinds {
+		count := b.Position.Pieces[player-1][kind].Count()
+		value += count * PlayerPiece{player, pieceType, Location{}}.GetValue()
 	}
 	return value
 }
@@ -497,43 +609,58 @@ func (b *Board) IsTerminal() bool {
 	if b.CheckPlayerInCheckmate(1) || b.CheckPlayerInCheckmate(2) || b.CheckPlayerInStalemate(1) || b.CheckPlayerInStalemate(2) {
 		return true
 	}
-	if b.HalfmoveClock >= 100 {
+	// The 50 move rule has to be claimed, only the 75 move rule ends the game
+	if b.HalfmoveClock >= 150 {
+		return true
+	}
+	if b.IsInsufficientMaterial() {
 		return true
 	}
 	return false
 }
 
-// Serialize the board to a string.
-// TODO: FIX
-func (b *Board) Serialize() string {
-	serialized := ""
-	//for i := 0; i < b.Height; i++ {
-	//	for j := 0; j < b.Width; j++ {
-	//		serialized += b.Locations[i][j].Serialize()
-	//	}
-	//}
-
-	// Serialize the board in FEN
-	for row := b.Height - 1; row >= 0; row-- {
-		col := 0
-		for col < b.Width {
-			// If the location is empty, count the number of empty spaces.
-			if b.Locations[row][col].IsEmpty() {
-				emptyCount := 0
-				for col < b.Width && b.Locations[row][col].IsEmpty() {
-					emptyCount++
-					col++
-				}
-				serialized += fmt.Sprintf("%d", emptyCount)
-			} else {
-				serialized += b.Locations[row][col].Serialize()
-				col++
-			}
-		}
-		if row != 0 {
-			serialized += "/"
+// Check if neither player has enough material left to checkmate.
+// This is the case for K vs K, K+B vs K, K+N vs K,
+// and when the only pieces besides the kings are bishops all on the same color.
+func (b *Board) IsInsufficientMaterial() bool {
+	pos := &b.Position
+	for player := 1; player <= 2; player++ {
+		if pos.PieceBitboard(player, 'P')|pos.PieceBitboard(player, 'R')|pos.PieceBitboard(player, 'Q') != 0 {
+			return false
 		}
 	}
+	knights := pos.PieceBitboard(1, 'N') | pos.PieceBitboard(2, 'N')
+	bishops := pos.PieceBitboard(1, 'B') | pos.PieceBitboard(2, 'B')
+
+	// A single minor piece can not mate.
+	if (knights | bishops).Count() <= 1 {
+		return true
+	}
+	// Bishops that are all on the same color can not mate either.
+	if knights == 0 && (bishops&darkSquares == 0 || bishops&^darkSquares == 0) {
+		return true
+	}
+	return false
+}
+
+// Check if the given player has the material to checkmate,
+// possibly with the help of the opponent's pieces.
+// This is false for a lone king and in dead positions,
+// a player running out of time against it only loses half a point.
+func (b *Board) CanCheckmate(player int) bool {
+	if b.IsInsufficientMaterial() {
+		return false
+	}
+	king := b.Position.PieceBitboard(player, 'K')
+	return b.Position.Occupied[player-1]&^king != 0
+}
+
+// Serialize the board to a string.
+// The format is the FEN piece placement followed by
+// State, HalfmoveClock, FullmoveNumber and the four castling rights.
+// Use ToFEN() to get a standard FEN string.
+func (b *Board) Serialize() string {
+	serialized := b.placementFEN()
 
 	serialized += fmt.Sprintf(" %d", b.State)
 	serialized += fmt.Sprintf(" %d", b.HalfmoveClock)
@@ -545,13 +672,18 @@ func (b *Board) Serialize() string {
 	return serialized
 }
 
-// Deserialize the board from a string.
-// TODO: FIX
+// Deserialize the board from a string made by Serialize().
 func (b *Board) Deserialize(serialized string) {
 	boardInfo := strings.Split(serialized, " ")
 
 	piecePosition := boardInfo[0]
 
+	b.Height = 8
+	b.Width = 8
+	b.clearLocations()
+	b.KingSideRookFile = [2]int{7, 7}
+	b.QueenSideRookFile = [2]int{0, 0}
+
 	// Deserialize the board in FEN
 	// Piece placement
 	ranks := strings.Split(piecePosition, "/")
@@ -580,4 +712,7 @@ func (b *Board) Deserialize(serialized string) {
 	b.WhiteKingSideCastle, _ = strconv.ParseBool(boardInfo[5])
 	b.BlackQueenSideCastle, _ = strconv.ParseBool(boardInfo[6])
 	b.BlackKingSideCastle, _ = strconv.ParseBool(boardInfo[7])
+
+	b.syncPosition()
+	b.Hash = b.ComputeHash()
 }
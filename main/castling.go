@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Castling rules for classical chess and Chess960.
+// The king always ends on the g file (king side) or the c file (queen side),
+// with the rook next to it on the f or d file.
+// In Chess960 the king and the rooks can start on any file of the first rank,
+// so the file of each castling rook is kept on the board.
+
+// Get the castling right flag of a player for one side.
+func (b *Board) castlingRight(player int, kingSide bool) *bool {
+	switch {
+	case player == 1 && kingSide:
+		return &b.WhiteKingSideCastle
+	case player == 1:
+		return &b.WhiteQueenSideCastle
+	case kingSide:
+		return &b.BlackKingSideCastle
+	default:
+		return &b.BlackQueenSideCastle
+	}
+}
+
+// Get the file of the rook a castling right belongs to.
+func (b *Board) castlingRookFile(player int, kingSide bool) int {
+	if kingSide {
+		return b.KingSideRookFile[player-1]
+	}
+	return b.QueenSideRookFile[player-1]
+}
+
+// Get the square of the rook that castles with the given castling move.
+func (b *Board) castlingRookLocation(m Move) Location {
+	player := 1
+	if m.From.X == 7 {
+		player = 2
+	}
+	return Location{m.From.X, b.castlingRookFile(player, m.To.Y == 6)}
+}
+
+// Get the square the rook ends on after the given castling move.
+func castlingRookTarget(m Move) Location {
+	if m.To.Y == 6 {
+		return Location{m.To.X, 5}
+	}
+	return Location{m.To.X, 3}
+}
+
+// Take away the castling right that belongs to the rook starting on the given square.
+func (b *Board) clearCastlingRight(l Location) {
+	for player, rank := 1, 0; player <= 2; player, rank = player+1, rank+7 {
+		if l.X != rank {
+			continue
+		}
+		for _, kingSide := range []bool{true, false} {
+			if l.Y == b.castlingRookFile(player, kingSide) {
+				*b.castlingRight(player, kingSide) = false
+			}
+		}
+	}
+}
+
+// Append the castling moves of a player.
+// Castling is legal when the player still has the right, the king and the rook
+// stand on their squares, the squares between them and their destinations are empty,
+// and the king is not in check and does not pass through or land on an attacked square.
+func (b *Board) appendCastlingMoves(moves []Move, player int) []Move {
+	if !*b.castlingRight(player, true) && !*b.castlingRight(player, false) {
+		return moves
+	}
+	if b.CheckPlayerInCheck(player) {
+		return moves
+	}
+	for _, kingSide := range []bool{true, false} {
+		if !*b.castlingRight(player, kingSide) {
+			continue
+		}
+		if move, ok := b.castlingMove(player, kingSide); ok {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// Get the castling move of a player for one side, if the pieces allow it.
+// The castling right and the king being in check are not checked here.
+func (b *Board) castlingMove(player int, kingSide bool) (Move, bool) {
+	rank := 0
+	if player == 2 {
+		rank = 7
+	}
+	kingSq := b.Position.KingSquare(player)
+	if kingSq < 0 || SquareLocation(kingSq).X != rank {
+		return Move{}, false
+	}
+	kingFrom := SquareLocation(kingSq)
+	rookFrom := Location{rank, b.castlingRookFile(player, kingSide)}
+	kingTo := Location{rank, 2}
+	if kingSide {
+		kingTo = Location{rank, 6}
+	}
+	rookTo := castlingRookTarget(Move{To: kingTo})
+
+	// The rook must still be there.
+	if !b.Position.PieceBitboard(player, 'R').Has(SquareOf(rookFrom)) {
+		return Move{}, false
+	}
+
+	// All the squares the king and the rook pass must be empty,
+	// apart from the king and the rook themselves.
+	occupied := b.Position.All() &^ (Bitboard(1) << uint(SquareOf(kingFrom))) &^ (Bitboard(1) << uint(SquareOf(rookFrom)))
+	low, high := kingFrom.Y, kingFrom.Y
+	for _, file := range []int{rookFrom.Y, kingTo.Y, rookTo.Y} {
+		low, high = Min(low, file), Max(high, file)
+	}
+	for file := low; file <= high; file++ {
+		if occupied.Has(SquareOf(Location{rank, file})) {
+			return Move{}, false
+		}
+	}
+
+	// The king must not pass through or land on an attacked square.
+	low, high = Min(kingFrom.Y, kingTo.Y), Max(kingFrom.Y, kingTo.Y)
+	for file := low; file <= high; file++ {
+		if b.IsSquareAttacked(Location{rank, file}, 3-player) {
+			return Move{}, false
+		}
+	}
+
+	return Move{'K', 'K', true, kingFrom, kingTo}, true
+}
+
+// Set the castling rights from the castling field of a FEN string.
+// Besides the standard "KQkq" this accepts X-FEN, where K and Q stand for the
+// outermost rook, and Shredder-FEN, which gives the file of the rook, e.g. "HAha".
+// Rights that do not match the king and rook placement are reported as an error,
+// but are still set so InitFEN can load any position.
+func (b *Board) setCastlingRights(castling string) error {
+	b.WhiteKingSideCastle = false
+	b.WhiteQueenSideCastle = false
+	b.BlackKingSideCastle = false
+	b.BlackQueenSideCastle = false
+	b.KingSideRookFile = [2]int{7, 7}
+	b.QueenSideRookFile = [2]int{0, 0}
+	if castling == "-" {
+		return nil
+	}
+
+	var err error
+	for _, char := range castling {
+		player, rank, letter := 1, 0, char
+		if char >= 'a' && char <= 'z' {
+			player, rank, letter = 2, 7, char&'_'
+		}
+
+		// Find the king on its first rank
+		kingFile := -1
+		for file := 0; file < 8; file++ {
+			if b.GetPiece(rank, file) == (PlayerPiece{player, 'K', Location{rank, file}}) {
+				kingFile = file
+			}
+		}
+
+		var kingSide bool
+		rookFile := -1
+		switch {
+		case letter == 'K':
+			kingSide = true
+			rookFile = b.outermostRookFile(player, kingFile, true)
+		case letter == 'Q':
+			kingSide = false
+			rookFile = b.outermostRookFile(player, kingFile, false)
+		case letter >= 'A' && letter <= 'H':
+			rookFile = int(letter - 'A')
+			kingSide = rookFile > kingFile
+			b.Chess960 = true
+		default:
+			return fmt.Errorf("invalid FEN: bad castling availability %q", castling)
+		}
+
+		right := b.castlingRight(player, kingSide)
+		if *right {
+			return fmt.Errorf("invalid FEN: castling right '%c' given twice", char)
+		}
+		*right = true
+
+		if kingFile < 0 || rookFile < 0 || b.GetPiece(rank, rookFile) != (PlayerPiece{player, 'R', Location{rank, rookFile}}) {
+			if err == nil {
+				err = fmt.Errorf("invalid FEN: castling right '%c' without king and rook on their squares", char)
+			}
+			continue
+		}
+		if kingSide {
+			b.KingSideRookFile[player-1] = rookFile
+		} else {
+			b.QueenSideRookFile[player-1] = rookFile
+		}
+		if kingFile != 4 || (kingSide && rookFile != 7) || (!kingSide && rookFile != 0) {
+			b.Chess960 = true
+		}
+	}
+	return err
+}
+
+// Get the file of the rook furthest from the king on one side of it, or -1 if there is none.
+func (b *Board) outermostRookFile(player int, kingFile int, kingSide bool) int {
+	rank := 0
+	if player == 2 {
+		rank = 7
+	}
+	if kingFile < 0 {
+		return -1
+	}
+	if kingSide {
+		for file := 7; file > kingFile; file-- {
+			if b.GetPiece(rank, file) == (PlayerPiece{player, 'R', Location{rank, file}}) {
+				return file
+			}
+		}
+	} else {
+		for file := 0; file < kingFile; file++ {
+			if b.GetPiece(rank, file) == (PlayerPiece{player, 'R', Location{rank, file}}) {
+				return file
+			}
+		}
+	}
+	return -1
+}
+
+// Get the castling field of the FEN string.
+// With shredder set the rook files are always given (Shredder-FEN),
+// otherwise K and Q are used unless another rook stands further out (X-FEN).
+func (b *Board) castlingFEN(shredder bool) string {
+	castling := ""
+	for player := 1; player <= 2; player++ {
+		rank := 0
+		if player == 2 {
+			rank = 7
+		}
+		for _, kingSide := range []bool{true, false} {
+			if !*b.castlingRight(player, kingSide) {
+				continue
+			}
+			rookFile := b.castlingRookFile(player, kingSide)
+			letter := 'A' + rune(rookFile)
+			if !shredder {
+				kingFile := -1
+				if kingSq := b.Position.KingSquare(player); kingSq >= 0 {
+					kingFile = SquareLocation(kingSq).Y
+				}
+				outermost := b.outermostRookFile(player, kingFile, kingSide)
+				if outermost < 0 || outermost == rookFile {
+					letter = 'Q'
+					if kingSide {
+						letter = 'K'
+					}
+				}
+			}
+			if rank == 7 {
+				letter += 32
+			}
+			castling += string(letter)
+		}
+	}
+	if castling == "" {
+		return "-"
+	}
+	return castling
+}
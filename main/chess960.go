@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Chess960 (Fischer Random Chess) starting positions.
+// The 960 positions are numbered as in the Scharnagl scheme,
+// position 518 is the classical starting position.
+
+// Placements of the two knights on the five squares left after the bishops and the queen.
+var chess960Knights = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+	{1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// Get the white back rank of a Chess960 starting position, e.g. "RNBQKBNR" for 518.
+func Chess960BackRank(index int) (string, error) {
+	if index < 0 || index >= 960 {
+		return "", fmt.Errorf("Chess960 position must be between 0 and 959, got %d", index)
+	}
+	rank := make([]rune, 8)
+
+	// Bishops on opposite colors
+	rank[(index%4)*2+1] = 'B'
+	index /= 4
+	rank[(index%4)*2] = 'B'
+	index /= 4
+
+	// Queen on one of the six empty squares
+	empty := emptyFiles(rank)
+	rank[empty[index%6]] = 'Q'
+	index /= 6
+
+	// Knights on two of the five empty squares
+	empty = emptyFiles(rank)
+	rank[empty[chess960Knights[index][0]]] = 'N'
+	rank[empty[chess960Knights[index][1]]] = 'N'
+
+	// King between the two rooks
+	empty = emptyFiles(rank)
+	rank[empty[0]] = 'R'
+	rank[empty[1]] = 'K'
+	rank[empty[2]] = 'R'
+
+	return string(rank), nil
+}
+
+// Get the files of a back rank that have no piece yet.
+func emptyFiles(rank []rune) []int {
+	files := []int{}
+	for file, piece := range rank {
+		if piece == 0 {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Get the FEN string of a Chess960 starting position.
+func Chess960FEN(index int) (string, error) {
+	backRank, err := Chess960BackRank(index)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(backRank) + "/pppppppp/8/8/8/8/PPPPPPPP/" + backRank + " w KQkq - 0 1", nil
+}
+
+// Get a random Chess960 starting position.
+func RandomChess960Index() int {
+	return rand.Intn(960)
+}
+
+// Init the board with a Chess960 starting position.
+func (b *Board) InitChess960(index int) error {
+	fen, err := Chess960FEN(index)
+	if err != nil {
+		return err
+	}
+	*b = InitFEN(fen)
+	b.Chess960 = true
+	return nil
+}
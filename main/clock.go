@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chess clocks.
+// A time control has one stage or more, e.g. 40 moves in 90 minutes and then 30 minutes
+// for the rest of the game, with 30 seconds added for every move: "40/5400+30:1800+30".
+
+// How the bonus time of a stage is given.
+type ClockMode int
+
+const (
+	// Fischer increment: the bonus is added after every move.
+	Increment ClockMode = iota
+	// Bronstein delay: after every move the time used is given back, up to the bonus.
+	BronsteinDelay
+	// Simple delay: the clock only starts running after the bonus time.
+	SimpleDelay
+)
+
+// A stage of a time control.
+type TimeControlStage struct {
+	// Number of moves of each player in the stage, 0 for the rest of the game.
+	// When the last stage has a number of moves it is repeated.
+	Moves int
+	// Time for the stage, added to the clock when the stage starts
+	Time time.Duration
+	// Increment or delay per move
+	Bonus time.Duration
+	Mode  ClockMode
+}
+
+type TimeControl struct {
+	Stages []TimeControlStage
+}
+
+// Sudden death: all the moves in the given time.
+func SuddenDeath(total time.Duration) TimeControl {
+	return TimeControl{[]TimeControlStage{{0, total, 0, Increment}}}
+}
+
+// All the moves in the given time, with an increment or a delay for every move.
+func TimeControlWithBonus(total time.Duration, bonus time.Duration, mode ClockMode) TimeControl {
+	return TimeControl{[]TimeControlStage{{0, total, bonus, mode}}}
+}
+
+// Parse a time control in the format of the PGN TimeControl tag, with the times in seconds:
+// "300" sudden death, "300+2" Fischer increment, "40/5400+30:1800+30" multiple stages.
+// A delay is written "300d5" for simple delay and "300b5" for Bronstein delay.
+func ParseTimeControl(s string) (TimeControl, error) {
+	tc := TimeControl{}
+	for _, field := range strings.Split(s, ":") {
+		stage := TimeControlStage{}
+		if i := strings.Index(field, "/"); i >= 0 {
+			moves, err := strconv.Atoi(field[:i])
+			if err != nil || moves < 1 {
+				return tc, fmt.Errorf("invalid time control %q: bad number of moves", s)
+			}
+			stage.Moves = moves
+			field = field[i+1:]
+		}
+		if i := strings.IndexAny(field, "+db"); i >= 0 {
+			bonus, err := strconv.ParseFloat(field[i+1:], 64)
+			if err != nil || bonus < 0 {
+				return tc, fmt.Errorf("invalid time control %q: bad increment or delay", s)
+			}
+			stage.Bonus = time.Duration(bonus * float64(time.Second))
+			switch field[i] {
+			case 'd':
+				stage.Mode = SimpleDelay
+			case 'b':
+				stage.Mode = BronsteinDelay
+			}
+			field = field[:i]
+		}
+		seconds, err := strconv.ParseFloat(field, 64)
+		if err != nil || seconds <= 0 {
+			return tc, fmt.Errorf("invalid time control %q: bad time", s)
+		}
+		stage.Time = time.Duration(seconds * float64(time.Second))
+		tc.Stages = append(tc.Stages, stage)
+	}
+	return tc, nil
+}
+
+// Write the time control in the format read by ParseTimeControl.
+func (tc TimeControl) String() string {
+	stages := []string{}
+	for _, stage := range tc.Stages {
+		s := ""
+		if stage.Moves > 0 {
+			s = strconv.Itoa(stage.Moves) + "/"
+		}
+		s += strconv.FormatFloat(stage.Time.Seconds(), 'f', -1, 64)
+		if stage.Bonus > 0 {
+			s += string("+bd"[stage.Mode]) + strconv.FormatFloat(stage.Bonus.Seconds(), 'f', -1, 64)
+		}
+		stages = append(stages, s)
+	}
+	return strings.Join(stages, ":")
+}
+
+// The clocks of both players.
+type Clock struct {
+	Control TimeControl
+
+	// Time left of each player, indexed by player-1, not counting the running turn.
+	Remaining [2]time.Duration
+
+	// Number of moves each player made, and the stage each player is in.
+	moves [2]int
+	stage [2]int
+
+	// The time left and the stage of each player before each of their moves, see TakeBack.
+	history [2][]clockTurn
+
+	// The player whose clock is running, 0 if the clock is stopped, and since when.
+	running   int
+	turnStart time.Time
+
+	// Current time, time.Now unless replaced for testing.
+	now func() time.Time
+}
+
+// The clock of a player at the start of a turn.
+type clockTurn struct {
+	remaining time.Duration
+	stage     int
+}
+
+// Create a clock with the time of the first stage for both players.
+func NewClock(tc TimeControl) *Clock {
+	c := &Clock{Control: tc, now: time.Now}
+	if len(tc.Stages) > 0 {
+		c.Remaining = [2]time.Duration{tc.Stages[0].Time, tc.Stages[0].Time}
+	}
+	return c
+}
+
+// Get the stage a player is in.
+func (c *Clock) currentStage(player int) TimeControlStage {
+	return c.Control.Stages[c.stage[player-1]]
+}
+
+// Start the clock of a player, if it is not running yet.
+// The running turn of the other player is charged to them, e.g. after a take back.
+func (c *Clock) Start(player int) {
+	if c.running == player {
+		return
+	}
+	c.Stop()
+	c.running = player
+	c.turnStart = c.now()
+}
+
+// Stop the clock, charging the running turn.
+func (c *Clock) Stop() {
+	if c.running == 0 {
+		return
+	}
+	c.Remaining[c.running-1] = c.TimeLeft(c.running)
+	c.running = 0
+}
+
+// Get the player whose clock is running, 0 if the clock is stopped.
+func (c *Clock) Running() int {
+	return c.running
+}
+
+// Get the time used in the running turn that is charged to the player.
+func (c *Clock) charged(player int) time.Duration {
+	if c.running != player {
+		return 0
+	}
+	elapsed := c.now().Sub(c.turnStart)
+	if stage := c.currentStage(player); stage.Mode == SimpleDelay {
+		elapsed -= stage.Bonus
+		if elapsed < 0 {
+			elapsed = 0
+		}
+	}
+	return elapsed
+}
+
+// Get the time a player has left, counting the running turn.
+// Negative once the player's flag has fallen.
+func (c *Clock) TimeLeft(player int) time.Duration {
+	return c.Remaining[player-1] - c.charged(player)
+}
+
+// Get how long the player on move can still think before the flag falls.
+// With a simple delay this includes the part of the delay not used yet.
+func (c *Clock) TimeToFlag(player int) time.Duration {
+	left := c.TimeLeft(player)
+	if c.running == player {
+		if stage := c.currentStage(player); stage.Mode == SimpleDelay {
+			if unused := stage.Bonus - c.now().Sub(c.turnStart); unused > 0 {
+				left += unused
+			}
+		}
+	}
+	return left
+}
+
+// Check if a player is out of time.
+func (c *Clock) Flagged(player int) bool {
+	return c.TimeLeft(player) <= 0
+}
+
+// Press the clock after the player made a move: charge the turn, give the bonus,
+// go to the next stage when it is reached and start the opponent's clock.
+// Returns false if the player ran out of time before the move.
+func (c *Clock) Press(player int) bool {
+	elapsed := c.now().Sub(c.turnStart)
+	if c.running != player {
+		elapsed = 0
+	}
+	left := c.TimeLeft(player)
+	if left <= 0 {
+		c.Remaining[player-1] = left
+		c.running = 0
+		return false
+	}
+
+	c.history[player-1] = append(c.history[player-1], clockTurn{c.Remaining[player-1], c.stage[player-1]})
+	stage := c.currentStage(player)
+	switch stage.Mode {
+	case Increment:
+		left += stage.Bonus
+	case BronsteinDelay:
+		if elapsed < stage.Bonus {
+			left += elapsed
+		} else {
+			left += stage.Bonus
+		}
+	}
+
+	// Next stage
+	c.moves[player-1]++
+	if stage.Moves > 0 && c.moves[player-1] == c.stageEnd(player) {
+		if c.stage[player-1]+1 < len(c.Control.Stages) {
+			c.stage[player-1]++
+		}
+		left += c.currentStage(player).Time
+	}
+
+	c.Remaining[player-1] = left
+	c.running = 3 - player
+	c.turnStart = c.now()
+	return true
+}
+
+// Put the clock of a player back to the start of their last move after it was taken back:
+// the time used for the move, its bonus and the time of a stage it reached are undone.
+// If the player's clock is running, their turn starts again.
+func (c *Clock) TakeBack(player int) {
+	history := c.history[player-1]
+	if len(history) == 0 {
+		return
+	}
+	turn := history[len(history)-1]
+	c.history[player-1] = history[:len(history)-1]
+	c.moves[player-1]--
+	c.Remaining[player-1] = turn.remaining
+	c.stage[player-1] = turn.stage
+	if c.running == player {
+		c.turnStart = c.now()
+	}
+}
+
+// Get the number of moves a player must have made at the end of the current stage.
+func (c *Clock) stageEnd(player int) int {
+	end := 0
+	for i := 0; i <= c.stage[player-1]; i++ {
+		end += c.Control.Stages[i].Moves
+	}
+	// The last stage repeats
+	last := c.Control.Stages[len(c.Control.Stages)-1]
+	for last.Moves > 0 && end < c.moves[player-1] {
+		end += last.Moves
+	}
+	return end
+}
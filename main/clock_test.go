@@ -0,0 +1,169 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Create a clock for the time control that reads a fake current time,
+// which only moves when the returned function is called.
+func testClock(t *testing.T, timeControl string) (*Clock, func(time.Duration)) {
+	t.Helper()
+	tc, err := ParseTimeControl(timeControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(tc)
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+// A move of a player: the time they think and the time they have left after pressing the clock.
+type clockMove struct {
+	player int
+	think  time.Duration
+	left   time.Duration
+}
+
+// Play the moves on the clock, checking the time left after each of them.
+func playClock(t *testing.T, c *Clock, advance func(time.Duration), moves []clockMove) {
+	t.Helper()
+	for i, move := range moves {
+		c.Start(move.player)
+		advance(move.think)
+		if !c.Press(move.player) {
+			t.Fatalf("move %d: player %d flagged", i+1, move.player)
+		}
+		if left := c.TimeLeft(move.player); left != move.left {
+			t.Errorf("move %d: player %d has %v left, want %v", i+1, move.player, left, move.left)
+		}
+	}
+}
+
+func TestClockBonus(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		name        string
+		timeControl string
+		moves       []clockMove
+	}{
+		{"sudden death", "60", []clockMove{{1, 10 * s, 50 * s}, {2, 5 * s, 55 * s}, {1, 10 * s, 40 * s}}},
+		{"increment", "60+2", []clockMove{{1, 10 * s, 52 * s}, {2, 5 * s, 57 * s}, {1, 0, 54 * s}}},
+		{"Bronstein delay", "60b3", []clockMove{{1, 2 * s, 60 * s}, {2, 5 * s, 58 * s}, {1, 3 * s, 60 * s}}},
+		{"simple delay", "60d3", []clockMove{{1, 2 * s, 60 * s}, {2, 5 * s, 58 * s}, {1, 4 * s, 59 * s}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, advance := testClock(t, test.timeControl)
+			playClock(t, c, advance, test.moves)
+		})
+	}
+}
+
+// The time of a stage is added when a player reaches it, the last stage repeats if it has a number of moves.
+func TestClockStages(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		name        string
+		timeControl string
+		moves       []clockMove
+	}{
+		{"two stages", "2/10:5", []clockMove{{1, s, 9 * s}, {1, s, 13 * s}, {1, s, 12 * s}, {1, s, 11 * s}}},
+		{"repeated stage", "2/10", []clockMove{{1, s, 9 * s}, {1, s, 18 * s}, {1, s, 17 * s}, {1, s, 26 * s}}},
+		{"repeated last stage", "1/10:2/5", []clockMove{{1, s, 14 * s}, {1, s, 13 * s}, {1, s, 17 * s}, {1, s, 16 * s}, {1, s, 20 * s}}},
+		{"stages with increment", "1/10+1:5+2", []clockMove{{1, s, 15 * s}, {1, s, 16 * s}, {1, s, 17 * s}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, advance := testClock(t, test.timeControl)
+			playClock(t, c, advance, test.moves)
+		})
+	}
+}
+
+func TestClockFlag(t *testing.T) {
+	c, advance := testClock(t, "10")
+	c.Start(1)
+	advance(9 * time.Second)
+	if c.Flagged(1) || c.TimeToFlag(1) != time.Second {
+		t.Fatalf("after 9s: flagged %v, TimeToFlag %v, want false and 1s", c.Flagged(1), c.TimeToFlag(1))
+	}
+	if c.TimeLeft(2) != 10*time.Second {
+		t.Errorf("the stopped clock of the opponent has %v left, want 10s", c.TimeLeft(2))
+	}
+	advance(2 * time.Second)
+	if !c.Flagged(1) || c.TimeLeft(1) != -time.Second {
+		t.Fatalf("after 11s: flagged %v, TimeLeft %v, want true and -1s", c.Flagged(1), c.TimeLeft(1))
+	}
+	if c.Press(1) {
+		t.Error("Press of a flagged player succeeded")
+	}
+	if c.Running() != 0 || c.TimeLeft(1) != -time.Second {
+		t.Errorf("after the flag: running %d, TimeLeft %v, want 0 and -1s", c.Running(), c.TimeLeft(1))
+	}
+}
+
+// With a simple delay the flag falls only after the delay and the time left are used up.
+func TestClockFlagWithDelay(t *testing.T) {
+	c, advance := testClock(t, "10d5")
+	c.Start(1)
+	advance(2 * time.Second)
+	if c.TimeLeft(1) != 10*time.Second || c.TimeToFlag(1) != 13*time.Second {
+		t.Fatalf("in the delay: TimeLeft %v, TimeToFlag %v, want 10s and 13s", c.TimeLeft(1), c.TimeToFlag(1))
+	}
+	advance(5 * time.Second)
+	if c.TimeLeft(1) != 8*time.Second || c.TimeToFlag(1) != 8*time.Second {
+		t.Fatalf("after the delay: TimeLeft %v, TimeToFlag %v, want 8s and 8s", c.TimeLeft(1), c.TimeToFlag(1))
+	}
+	advance(8 * time.Second)
+	if !c.Flagged(1) {
+		t.Error("player not flagged after the delay and their time")
+	}
+}
+
+// Taking back moves puts the clock back to the start of the moves, also across stages.
+func TestClockTakeBack(t *testing.T) {
+	s := time.Second
+	c, advance := testClock(t, "2/10+1:20")
+	playClock(t, c, advance, []clockMove{{1, s, 10 * s}, {2, 2 * s, 9 * s}, {1, 3 * s, 28 * s}, {2, s, 29 * s}})
+
+	c.TakeBack(2)
+	c.TakeBack(1)
+	if c.TimeLeft(1) != 10*s || c.TimeLeft(2) != 9*s {
+		t.Fatalf("after the take back: %v and %v left, want 10s and 9s", c.TimeLeft(1), c.TimeLeft(2))
+	}
+	if c.stage != [2]int{0, 0} || c.moves != [2]int{1, 1} {
+		t.Fatalf("after the take back: stages %v, moves %v, want [0 0] and [1 1]", c.stage, c.moves)
+	}
+
+	// The second move reaches the next stage again
+	playClock(t, c, advance, []clockMove{{1, 4 * s, 27 * s}, {2, 0, 30 * s}})
+
+	// Nothing to take back for a player who has not moved
+	c, advance = testClock(t, "60")
+	c.TakeBack(1)
+	if c.TimeLeft(1) != 60*s || c.moves[0] != 0 {
+		t.Errorf("take back without moves changed the clock: %v left, %d moves", c.TimeLeft(1), c.moves[0])
+	}
+}
+
+// A player whose move is taken back while their clock runs starts their turn again.
+func TestClockTakeBackRunning(t *testing.T) {
+	s := time.Second
+	c, advance := testClock(t, "60")
+	playClock(t, c, advance, []clockMove{{1, 5 * s, 55 * s}, {2, 5 * s, 55 * s}})
+	advance(10 * s)
+	c.TakeBack(2)
+	if c.Running() != 1 || c.TimeLeft(1) != 45*s {
+		t.Fatalf("running %d with %v left, want 1 with 45s", c.Running(), c.TimeLeft(1))
+	}
+	c.TakeBack(1)
+	if c.TimeLeft(1) != 60*s || c.TimeLeft(2) != 60*s {
+		t.Errorf("after the take back: %v and %v left, want 60s and 60s", c.TimeLeft(1), c.TimeLeft(2))
+	}
+	advance(3 * s)
+	if c.TimeLeft(1) != 57*s {
+		t.Errorf("the turn did not start again: %v left, want 57s", c.TimeLeft(1))
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Game events.
+// Code embedding a Game, like the server, subscribes to its events instead of polling the board:
+//
+//	game.Subscribe(func(event GameEvent) {
+//		switch event := event.(type) {
+//		case MoveEvent:
+//			...
+//		case GameOverEvent:
+//			...
+//		}
+//	})
+//
+// Listeners are called in the order they subscribed, from the goroutine calling Play.
+
+// One of the event types below.
+type GameEvent interface {
+	gameEvent()
+}
+
+// The game asks a player for their move.
+type TurnEvent struct {
+	Player int
+}
+
+// A move was made, or made again by Redo.
+type MoveEvent struct {
+	Player int
+	Move   Move
+	// The move in SAN, e.g. "Nf3"
+	SAN string
+	// Number of moves played, including this one
+	Ply int
+}
+
+// A move was taken back.
+type UndoEvent struct {
+	Move Move
+	SAN  string
+	// Number of moves played, without the one taken back
+	Ply int
+}
+
+// The player on move is in check, sent after the move giving check.
+type CheckEvent struct {
+	Player int
+}
+
+// A player offered a draw.
+type DrawOfferEvent struct {
+	Player int
+}
+
+// A player declined the draw the opponent offered.
+type DrawDeclinedEvent struct {
+	Player int
+}
+
+// The game did not accept an action of a player, e.g. a draw claim in a position that can not be claimed.
+type ActionRejectedEvent struct {
+	Player int
+	Reason string
+}
+
+// A player could not move, e.g. a remote player left or an engine failed or played an illegal move.
+// The game ends, the player loses by abandonment.
+type PlayerErrorEvent struct {
+	Player int
+	Err    error
+}
+
+// The clock of a player started running at the start of their turn.
+type ClockEvent struct {
+	Player int
+	// Time left of both players
+	White time.Duration
+	Black time.Duration
+}
+
+// The game is over.
+type GameOverEvent struct {
+	Result GameResult
+}
+
+func (TurnEvent) gameEvent()           {}
+func (MoveEvent) gameEvent()           {}
+func (UndoEvent) gameEvent()           {}
+func (CheckEvent) gameEvent()          {}
+func (DrawOfferEvent) gameEvent()      {}
+func (DrawDeclinedEvent) gameEvent()   {}
+func (ActionRejectedEvent) gameEvent() {}
+func (PlayerErrorEvent) gameEvent()    {}
+func (ClockEvent) gameEvent()          {}
+func (GameOverEvent) gameEvent()       {}
+
+type gameListener struct {
+	id     int
+	listen func(event GameEvent)
+}
+
+// Call the listener with every event of the game.
+// Returns a function that unsubscribes it.
+func (g *Game) Subscribe(listener func(event GameEvent)) func() {
+	g.lastListener++
+	id := g.lastListener
+	g.listeners = append(g.listeners, gameListener{id, listener})
+	return func() {
+		for i, l := range g.listeners {
+			if l.id == id {
+				g.listeners = append(g.listeners[:i:i], g.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Send an event to the listeners.
+func (g *Game) emit(event GameEvent) {
+	// A listener may unsubscribe while the event is sent
+	listeners := g.listeners
+	for _, l := range listeners {
+		l.listen(event)
+	}
+}
+
+// Listener printing the events to the console, e.g. for games played on the command line:
+//
+//	game.Subscribe(LogEvents)
+func LogEvents(event GameEvent) {
+	switch event := event.(type) {
+	case TurnEvent:
+		fmt.Printf("%s's turn\n", playerName(event.Player))
+	case MoveEvent:
+		fmt.Printf("%s played %s\n", playerName(event.Player), event.SAN)
+	case UndoEvent:
+		fmt.Printf("%s taken back\n", event.SAN)
+	case CheckEvent:
+		fmt.Printf("%s is in check\n", playerName(event.Player))
+	case DrawOfferEvent:
+		fmt.Printf("%s offers a draw\n", playerName(event.Player))
+	case DrawDeclinedEvent:
+		fmt.Println("Draw offer declined")
+	case ActionRejectedEvent:
+		fmt.Println(event.Reason)
+	case PlayerErrorEvent:
+		fmt.Printf("%s can not go on: %v\n", playerName(event.Player), event.Err)
+	case ClockEvent:
+		left := event.White
+		if event.Player == 2 {
+			left = event.Black
+		}
+		fmt.Printf("%s has %v left\n", playerName(event.Player), left.Round(time.Second))
+	case GameOverEvent:
+		fmt.Println(event.Result)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse and validate a FEN string.
+// Unlike InitFEN this never panics, malformed or impossible positions return an error.
+// Only the piece placement is required, the other fields default to "w - - 0 1".
+func ParseFEN(fen string) (Board, error) {
+	var b Board
+	b.Height = 8
+	b.Width = 8
+	b.LastMove = Move{
+		Type: ' ',
+	}
+	b.clearLocations()
+
+	fenParts := strings.Fields(fen)
+	if len(fenParts) == 0 {
+		return b, fmt.Errorf("invalid FEN: empty string")
+	}
+	if len(fenParts) > 6 {
+		return b, fmt.Errorf("invalid FEN: expected at most 6 fields, got %d", len(fenParts))
+	}
+	// Fill in the missing optional fields
+	defaults := []string{"", "w", "-", "-", "0", "1"}
+	fenParts = append(fenParts, defaults[len(fenParts):]...)
+
+	// Piece placement
+	ranks := strings.Split(fenParts[0], "/")
+	if len(ranks) != 8 {
+		return b, fmt.Errorf("invalid FEN: expected 8 ranks, got %d", len(ranks))
+	}
+	for i := 0; i < b.Height; i++ {
+		rank := ranks[7-i]
+		file := 0
+		for _, char := range rank {
+			if char >= '1' && char <= '8' {
+				file += int(char - '0')
+				if file > 8 {
+					return b, fmt.Errorf("invalid FEN: rank %d has more than 8 files", i+1)
+				}
+				continue
+			}
+			player := 1
+			pieceType := char
+			if char >= 'a' && char <= 'z' {
+				player = 2
+				pieceType = char & '_'
+			}
+			if pieceKind(pieceType) < 0 {
+				return b, fmt.Errorf("invalid FEN: unknown piece '%c' on rank %d", char, i+1)
+			}
+			if file >= 8 {
+				return b, fmt.Errorf("invalid FEN: rank %d has more than 8 files", i+1)
+			}
+			if pieceType == 'P' && (i == 0 || i == 7) {
+				return b, fmt.Errorf("invalid FEN: pawn on %s", LocationToGrid(Location{i, file}))
+			}
+			b.Locations[i][file] = PlayerPiece{player, pieceType, Location{i, file}}
+			file++
+		}
+		if file != 8 {
+			return b, fmt.Errorf("invalid FEN: rank %d has %d files, expected 8", i+1, file)
+		}
+	}
+	b.syncPosition()
+
+	// Kings
+	for player, name := range []string{"white", "black"} {
+		switch b.Position.Pieces[player][5].Count() {
+		case 0:
+			return b, fmt.Errorf("invalid FEN: missing %s king", name)
+		case 1:
+		default:
+			return b, fmt.Errorf("invalid FEN: more than one %s king", name)
+		}
+	}
+
+	// Active color
+	switch fenParts[1] {
+	case "w":
+		b.State = 1
+	case "b":
+		b.State = 2
+	default:
+		return b, fmt.Errorf("invalid FEN: active color must be w or b, got %q", fenParts[1])
+	}
+	if b.CheckPlayerInCheck(3 - b.State) {
+		return b, fmt.Errorf("invalid FEN: the side not to move is in check")
+	}
+
+	// Castling availability
+	if err := b.setCastlingRights(fenParts[2]); err != nil {
+		return b, err
+	}
+
+	// En passant target square
+	if fenParts[3] != "-" {
+		isValid, target := GridToLocation(fenParts[3])
+		if !isValid {
+			return b, fmt.Errorf("invalid FEN: bad en passant square %q", fenParts[3])
+		}
+		// The pawn that just moved two squares stands in front of the target.
+		rank, pawn, pawnPlayer := 5, Location{4, target.Y}, 2
+		if b.State == 2 {
+			rank, pawn, pawnPlayer = 2, Location{3, target.Y}, 1
+		}
+		origin := Location{2*target.X - pawn.X, target.Y}
+		if target.X != rank {
+			return b, fmt.Errorf("invalid FEN: en passant square %s is on the wrong rank", fenParts[3])
+		}
+		if b.GetPieceAtLocation(pawn) != (PlayerPiece{pawnPlayer, 'P', pawn}) || !b.GetPieceAtLocation(target).IsEmpty() || !b.GetPieceAtLocation(origin).IsEmpty() {
+			return b, fmt.Errorf("invalid FEN: no pawn can have just moved over en passant square %s", fenParts[3])
+		}
+		b.EnPassantTargetSquare = target
+	}
+
+	// Halfmove clock
+	halfmoveClock, err := strconv.Atoi(fenParts[4])
+	if err != nil || halfmoveClock < 0 {
+		return b, fmt.Errorf("invalid FEN: bad halfmove clock %q", fenParts[4])
+	}
+	b.HalfmoveClock = halfmoveClock
+
+	// Fullmove number
+	fullmoveNumber, err := strconv.Atoi(fenParts[5])
+	if err != nil || fullmoveNumber < 1 {
+		return b, fmt.Errorf("invalid FEN: bad fullmove number %q", fenParts[5])
+	}
+	b.FullmoveNumber = fullmoveNumber
+
+	b.Hash = b.ComputeHash()
+	return b, nil
+}
+
+// FEN string of the classical starting position.
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Export the board as a FEN string.
+// The result can be loaded back with InitFEN.
+// Chess960 castling rights are written as X-FEN.
+func (b *Board) ToFEN() string {
+	return b.toFEN(false)
+}
+
+// Export the board as a Shredder-FEN string,
+// where the castling rights give the files of the rooks.
+func (b *Board) ToShredderFEN() string {
+	return b.toFEN(true)
+}
+
+func (b *Board) toFEN(shredder bool) string {
+	fen := b.placementFEN()
+
+	// Active color
+	if b.State == 2 {
+		fen += " b"
+	} else {
+		fen += " w"
+	}
+
+	// Castling availability
+	fen += " " + b.castlingFEN(shredder)
+
+	// En passant target square
+	if target, ok := b.enPassantTarget(b.State); ok {
+		fen += " " + LocationToGrid(target)
+	} else {
+		fen += " -"
+	}
+
+	// Halfmove clock and fullmove number
+	fen += fmt.Sprintf(" %d %d", b.HalfmoveClock, b.FullmoveNumber)
+	return fen
+}
+
+// Get the piece placement part of the FEN string.
+func (b *Board) placementFEN() string {
+	var sb strings.Builder
+	for row := b.Height - 1; row >= 0; row-- {
+		col := 0
+		for col < b.Width {
+			// If the location is empty, count the number of empty spaces.
+			if b.Locations[row][col].IsEmpty() {
+				emptyCount := 0
+				for col < b.Width && b.Locations[row][col].IsEmpty() {
+					emptyCount++
+					col++
+				}
+				sb.WriteString(fmt.Sprintf("%d", emptyCount))
+			} else {
+				sb.WriteString(b.Locations[row][col].Serialize())
+				col++
+			}
+		}
+		if row != 0 {
+			sb.WriteString("/")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFENRoundTrip(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/8/8/8/8/8/8/R3K2R b Kq - 12 40",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+	for _, fen := range fens {
+		b, err := ParseFEN(fen)
+		if err != nil {
+			t.Errorf("ParseFEN(%q): %v", fen, err)
+			continue
+		}
+		if got := b.ToFEN(); got != fen {
+			t.Errorf("ParseFEN(%q).ToFEN() = %q", fen, got)
+		}
+		if b.Hash != b.ComputeHash() {
+			t.Errorf("ParseFEN(%q) has a stale hash", fen)
+		}
+	}
+}
+
+// Chess960 castling rights are read as Shredder-FEN and written as X-FEN by ToFEN.
+func TestParseFENChess960(t *testing.T) {
+	fen := "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9"
+	b, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.ToShredderFEN(); got != fen {
+		t.Errorf("ToShredderFEN() = %q, want %q", got, fen)
+	}
+	xfen := "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9"
+	if got := b.ToFEN(); got != xfen {
+		t.Errorf("ToFEN() = %q, want %q", got, xfen)
+	}
+	if again, err := ParseFEN(xfen); err != nil || again.ToShredderFEN() != fen {
+		t.Errorf("ParseFEN(%q) does not give the same castling rights back: %v", xfen, err)
+	}
+}
+
+func TestParseFENDefaults(t *testing.T) {
+	b, err := ParseFEN("4k3/8/8/8/8/8/8/4K3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.ToFEN(), "4k3/8/8/8/8/8/8/4K3 w - - 0 1"; got != want {
+		t.Errorf("ToFEN() = %q, want %q", got, want)
+	}
+}
+
+func TestParseFENErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		err  string
+	}{
+		{"empty", "", "empty string"},
+		{"too many fields", "4k3/8/8/8/8/8/8/4K3 w - - 0 1 x", "at most 6 fields"},
+		{"missing rank", "4k3/8/8/8/8/8/4K3 w - - 0 1", "expected 8 ranks"},
+		{"long rank", "4k4/8/8/8/8/8/8/4K3 w - - 0 1", "more than 8 files"},
+		{"short rank", "4k2/8/8/8/8/8/8/4K3 w - - 0 1", "expected 8"},
+		{"unknown piece", "4k3/8/8/8/8/8/8/4K2X w - - 0 1", "unknown piece"},
+		{"pawn on the last rank", "P3k3/8/8/8/8/8/8/4K3 w - - 0 1", "pawn on a8"},
+		{"missing king", "8/8/8/8/8/8/8/4K3 w - - 0 1", "missing black king"},
+		{"two kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "more than one white king"},
+		{"bad color", "4k3/8/8/8/8/8/8/4K3 x - - 0 1", "active color"},
+		{"side not to move in check", "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "not to move is in check"},
+		{"bad castling", "r3k2r/8/8/8/8/8/8/R3K2R w KX - 0 1", "bad castling availability"},
+		{"castling twice", "r3k2r/8/8/8/8/8/8/R3K2R w KK - 0 1", "given twice"},
+		{"castling without rook", "r3k3/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "without king and rook"},
+		{"bad en passant square", "4k3/8/8/8/8/8/8/4K3 w - z9 0 1", "bad en passant square"},
+		{"en passant on the wrong rank", "4k3/8/8/3pP3/8/8/8/4K3 w - d5 0 1", "wrong rank"},
+		{"en passant without pawn", "4k3/8/8/4P3/8/8/8/4K3 w - d6 0 1", "no pawn can have just moved"},
+		{"bad halfmove clock", "4k3/8/8/8/8/8/8/4K3 w - - -1 1", "bad halfmove clock"},
+		{"bad fullmove number", "4k3/8/8/8/8/8/8/4K3 w - - 0 0", "bad fullmove number"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFEN(test.fen)
+			if err == nil {
+				t.Fatalf("ParseFEN(%q) did not fail", test.fen)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseFEN(%q) = %q, want an error containing %q", test.fen, err, test.err)
+			}
+		})
+	}
+}
@@ -1,39 +1,283 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
 
 // The game logic
 
 type Game struct {
 	Board Board
-	State GameState
+
+	// The result of the game, Ongoing until it is over.
+	// The player on move is Board.State.
+	Result GameResult
 
 	WhitePlayer PlayerController
 	BlackPlayer PlayerController
-}
 
-// GameState:
-// 0 - white player's turn
-// 1 - black player's turn
-// 2 - white player won
-// 3 - black player won
-// 4 - draw
-type GameState int
+	// Zobrist hashes of every position of the game, including the current one.
+	// Used to detect repetitions.
+	Positions []uint64
+
+	// FEN string of the starting position and the moves played from it.
+	StartFEN string
+	Moves    []Move
+
+	// Undo information of every move in Moves, and the moves taken back that can be redone
+	// with the result the game had before they were taken back. See history.go.
+	undos       []UndoInfo
+	redoMoves   []Move
+	redoResults []GameResult
+
+	// PGN tags such as "Event" or "White", see SetTag.
+	Tags map[string]string
+
+	// Player offering a draw, 0 if there is no offer.
+	// The offer expires when the opponent makes a move.
+	DrawOffer int
+
+	// The clocks of the players, nil for a game without time control, see SetTimeControl.
+	Clock *Clock
+
+	// Listeners of the game events, see events.go.
+	listeners    []gameListener
+	lastListener int
+
+	// Actions of the players sent from other goroutines, see SendAction.
+	actions chan Move
+	// Move of the player on move that came in with an action, played at their next turn.
+	pendingMove *Move
+
+	// Held by Play, except while it waits for a move, see Lock.
+	mu sync.Mutex
+}
 
 func (g *Game) Init(whitePlayer PlayerController, blackPlayer PlayerController) {
 	g.Board.Init()
-	g.State = 0
-	g.WhitePlayer = whitePlayer
-	g.BlackPlayer = blackPlayer
+	g.start(whitePlayer, blackPlayer)
 }
 
 // Init the game with a FEN string
-// For debugging purposes
-func (g *Game) InitWithFEN(fen string, whitePlayer PlayerController, blackPlayer PlayerController) {
-	g.Board = InitFEN(fen)
-	g.State = 0
+// Returns an error if the FEN string is not a valid position.
+func (g *Game) InitWithFEN(fen string, whitePlayer PlayerController, blackPlayer PlayerController) error {
+	board, err := ParseFEN(fen)
+	if err != nil {
+		return err
+	}
+	g.Board = board
+	g.start(whitePlayer, blackPlayer)
+	return nil
+}
+
+// Init the game with a Chess960 starting position, see Chess960BackRank.
+// Returns an error if the index is not between 0 and 959.
+func (g *Game) InitChess960(index int, whitePlayer PlayerController, blackPlayer PlayerController) error {
+	if err := g.Board.InitChess960(index); err != nil {
+		return err
+	}
+	g.start(whitePlayer, blackPlayer)
+	return nil
+}
+
+// Start the game from the position on the board.
+func (g *Game) start(whitePlayer PlayerController, blackPlayer PlayerController) {
+	g.Result = GameResult{}
 	g.WhitePlayer = whitePlayer
 	g.BlackPlayer = blackPlayer
+	g.Positions = []uint64{g.Board.Hash}
+	g.StartFEN = g.Board.ToFEN()
+	g.Moves = nil
+	g.undos = nil
+	g.redoMoves = nil
+	g.redoResults = nil
+	g.DrawOffer = 0
+	g.actions = make(chan Move, 4)
+	g.pendingMove = nil
+}
+
+// Actions a PlayerController can return from GetMove instead of a move.
+// The Piece of the action is the player taking it, 'W' or 'B',
+// so a remote player can also act while it is the opponent's turn.
+
+// Resign the game.
+func ResignMove(player int) Move {
+	return Move{Type: 'R', Piece: playerLetter(player)}
+}
+
+// Offer the opponent a draw.
+func OfferDrawMove(player int) Move {
+	return Move{Type: 'O', Piece: playerLetter(player)}
+}
+
+// Accept the draw the opponent offered.
+func AcceptDrawMove(player int) Move {
+	return Move{Type: 'A', Piece: playerLetter(player)}
+}
+
+// Decline the draw the opponent offered.
+func DeclineDrawMove(player int) Move {
+	return Move{Type: 'N', Piece: playerLetter(player)}
+}
+
+// Leave the game, which the opponent wins.
+func AbandonMove(player int) Move {
+	return Move{Type: 'X', Piece: playerLetter(player)}
+}
+
+// Claim a draw, see Game.CanClaimDraw. Only the player on move can claim it.
+func ClaimDrawMove(player int) Move {
+	return Move{Type: 'D', Piece: playerLetter(player)}
+}
+
+// Take back the player's last move, see Game.TakeBack.
+func TakeBackMove(player int) Move {
+	return Move{Type: 'U', Piece: playerLetter(player)}
+}
+
+// Send an action of a player, e.g. a resignation from a client, while the game waits for a move.
+// Play takes it instead of the move of the player on move, so either player can act at any time.
+// Returns false if the action is dropped because too many actions are waiting already.
+func (g *Game) SendAction(action Move) bool {
+	select {
+	case g.actions <- action:
+		return true
+	default:
+		return false
+	}
+}
+
+// Lock the game, to read or change it from another goroutine than the one calling Play.
+// Play holds the lock while it changes the game and while the listeners are called,
+// so listeners must not lock it.
+func (g *Game) Lock() {
+	g.mu.Lock()
+}
+
+func (g *Game) Unlock() {
+	g.mu.Unlock()
+}
+
+func playerLetter(player int) rune {
+	if player == 2 {
+		return 'B'
+	}
+	return 'W'
+}
+
+// Get the player taking an action.
+func actionPlayer(move Move) int {
+	if move.Piece == 'B' {
+		return 2
+	}
+	return 1
+}
+
+func playerName(player int) string {
+	if player == 2 {
+		return "Black player"
+	}
+	return "White player"
+}
+
+// Check if the game is over.
+func (g *Game) IsOver() bool {
+	return g.Result.IsOver()
+}
+
+// End the game.
+// Always returns true, so Play can return it.
+func (g *Game) end(result GameResult) bool {
+	g.Result = result
+	if g.Clock != nil {
+		g.Clock.Stop()
+	}
+	g.emit(GameOverEvent{result})
+	return true
+}
+
+// Play the game with the given time control.
+// The clock of the first player starts when they are asked for their first move.
+func (g *Game) SetTimeControl(tc TimeControl) {
+	g.Clock = NewClock(tc)
+	g.SetTag("TimeControl", tc.String())
+}
+
+// Ask a player for their move.
+// With a clock the player only has until their time runs out,
+// then the context of the player is done and context.DeadlineExceeded is returned.
+// The player gets a copy of the board, so it can not change the game
+// if it is still thinking when the game stops waiting for it.
+// An action sent with SendAction is returned instead of the move,
+// a move the player returns when its context is done after that is kept for their next turn.
+// The game is unlocked while it waits.
+func (g *Game) getMove(controller PlayerController) (Move, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if g.Clock != nil {
+		player := g.Board.State
+		g.Clock.Start(player)
+		g.emit(ClockEvent{player, g.Clock.TimeLeft(1), g.Clock.TimeLeft(2)})
+		ctx, cancel = context.WithTimeout(context.Background(), g.Clock.TimeToFlag(player))
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	// Let a player that is still thinking know the move is not needed anymore
+	defer cancel()
+	if g.pendingMove != nil {
+		move := *g.pendingMove
+		g.pendingMove = nil
+		return move, nil
+	}
+
+	type result struct {
+		move Move
+		err  error
+	}
+	board := g.Board.Copy()
+	results := make(chan result, 1)
+	go func() {
+		move, err := controller.GetMove(ctx, &board)
+		results <- result{move, err}
+	}()
+
+	g.mu.Unlock()
+	defer g.mu.Lock()
+	select {
+	case r := <-results:
+		return r.move, r.err
+	case action := <-g.actions:
+		// The player may have moved at the same time, the move is kept for their next turn
+		cancel()
+		if r := <-results; r.err == nil {
+			g.pendingMove = &r.move
+		}
+		return action, nil
+	case <-ctx.Done():
+		return Move{}, ctx.Err()
+	}
+}
+
+// Check if a move returned by a player is one of their legal moves.
+func (g *Game) isLegalMove(player int, move Move) bool {
+	for _, legal := range g.Board.GetPlayerLegalMoves(player) {
+		if legal.Type == move.Type && legal.Piece == move.Piece && legal.From == move.From && legal.To == move.To {
+			return true
+		}
+	}
+	return false
+}
+
+// End the game when a player ran out of time.
+// The opponent wins, unless they do not have the material to checkmate.
+func (g *Game) timeout(player int) bool {
+	if !g.Board.CanCheckmate(3 - player) {
+		return g.end(DrawBy(Timeout))
+	}
+	return g.end(Win(3-player, Timeout))
 }
 
 func (g *Game) Print() {
@@ -43,49 +287,158 @@ func (g *Game) Print() {
 // Initiate the game logic
 // Returns true if the game is over
 func (g *Game) Play() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	// If the game is over, do nothing.
-	if g.State == 2 || g.State == 3 || g.State == 4 {
+	if g.IsOver() {
 		return true
 	}
 
 	// If it's white player's turn, get the move from the white player
 	var move = Move{}
-	if g.State == 0 {
-		fmt.Println("White player's turn")
-		move = g.WhitePlayer.GetMove(&g.Board)
-		g.Board.MakeMove(move)
-		g.State = 1
+	var err error
+	player := g.Board.State
+	g.emit(TurnEvent{player})
+	if player == 1 {
+		move, err = g.getMove(g.WhitePlayer)
 	} else {
-		fmt.Println("Black player's turn")
-		move = g.BlackPlayer.GetMove(&g.Board)
-		g.Board.MakeMove(move)
-		g.State = 0
+		move, err = g.getMove(g.BlackPlayer)
+	}
+	// A player who can not move, e.g. a remote player who left or an engine that failed, loses
+	if errors.Is(err, context.DeadlineExceeded) {
+		return g.timeout(player)
+	}
+	if err != nil {
+		g.emit(PlayerErrorEvent{player, err})
+		return g.end(Win(3-player, Abandonment))
+	}
+
+	switch move.Type {
+	// The player may claim a draw instead of moving
+	case 'D':
+		if move.Piece != 0 && actionPlayer(move) != player {
+			g.emit(ActionRejectedEvent{actionPlayer(move), "Draw claim rejected, only the player on move can claim a draw"})
+			return false
+		}
+		if g.CanClaimThreefoldRepetition() {
+			return g.end(DrawBy(ThreefoldRepetition))
+		}
+		if g.CanClaimFiftyMoveRule() {
+			return g.end(DrawBy(FiftyMoveRule))
+		}
+		g.emit(ActionRejectedEvent{player, "Draw claim rejected, the position did not occur three times and the last 50 moves had a capture or a pawn move"})
+		return false
+
+	// The player may take back their last move
+	case 'U':
+		if !g.TakeBack(actionPlayer(move)) {
+			g.emit(ActionRejectedEvent{actionPlayer(move), "Take back rejected, there is no move to take back"})
+		}
+		return false
+
+	case 'R':
+		return g.end(Win(3-actionPlayer(move), Resignation))
+
+	case 'X':
+		return g.end(Win(3-actionPlayer(move), Abandonment))
+
+	case 'O':
+		g.DrawOffer = actionPlayer(move)
+		g.emit(DrawOfferEvent{g.DrawOffer})
+		return false
+
+	case 'A':
+		if g.DrawOffer == 0 || g.DrawOffer == actionPlayer(move) {
+			g.emit(ActionRejectedEvent{actionPlayer(move), "There is no draw offer to accept"})
+			return false
+		}
+		return g.end(DrawBy(Agreement))
+
+	case 'N':
+		if g.DrawOffer != 0 && g.DrawOffer != actionPlayer(move) {
+			g.DrawOffer = 0
+			g.emit(DrawDeclinedEvent{actionPlayer(move)})
+		}
+		return false
+	}
+
+	if !g.isLegalMove(player, move) {
+		g.emit(PlayerErrorEvent{player, fmt.Errorf("illegal move %s", move.ToString())})
+		return g.end(Win(3-player, Abandonment))
+	}
+
+	// The move only counts if it was made in time
+	if g.Clock != nil && !g.Clock.Press(player) {
+		return g.timeout(player)
+	}
+
+	g.makeMove(move)
+	g.redoMoves = nil
+	g.redoResults = nil
+
+	// A move of the opponent ends the draw offer
+	if g.DrawOffer == g.Board.State {
+		g.DrawOffer = 0
+	}
+
+	if g.Board.CheckPlayerInCheck(g.Board.State) {
+		g.emit(CheckEvent{g.Board.State})
 	}
 
 	// Check if the game is over
 	if g.Board.CheckPlayerInCheckmate(1) {
-		g.State = 3
-		fmt.Println("Black player won")
-		return true
+		return g.end(Win(2, Checkmate))
 	}
 	if g.Board.CheckPlayerInCheckmate(2) {
-		g.State = 2
-		fmt.Println("White player won")
-		return true
+		return g.end(Win(1, Checkmate))
 	}
 
 	// Check if the game is a draw
 	if g.Board.CheckPlayerInStalemate(1) || g.Board.CheckPlayerInStalemate(2) {
-		g.State = 4
-		fmt.Println("Draw by stalemate")
-		return true
+		return g.end(DrawBy(Stalemate))
 	}
-	// Check half move rule
-	if g.Board.HalfmoveClock >= 100 {
-		g.State = 4
-		fmt.Println("Draw by half move rule")
-		return true
+	// Check the 75 move rule, the 50 move rule has to be claimed
+	if g.Board.HalfmoveClock >= 150 {
+		return g.end(DrawBy(SeventyFiveMoveRule))
+	}
+	// Check if there is enough material left to checkmate
+	if g.Board.IsInsufficientMaterial() {
+		return g.end(DrawBy(InsufficientMaterial))
+	}
+	// Check fivefold repetition
+	if g.RepetitionCount() >= 5 {
+		return g.end(DrawBy(FivefoldRepetition))
 	}
 
 	return false
 }
+
+// Get how many times the current position occurred in the game.
+// Positions are the same when the pieces, the side to move,
+// the castling rights and the en passant square are the same.
+func (g *Game) RepetitionCount() int {
+	count := 0
+	for _, hash := range g.Positions {
+		if hash == g.Board.Hash {
+			count++
+		}
+	}
+	return count
+}
+
+// Check if the player to move can claim a draw by threefold repetition.
+func (g *Game) CanClaimThreefoldRepetition() bool {
+	return g.RepetitionCount() >= 3
+}
+
+// Check if the player to move can claim a draw by the 50 move rule:
+// no capture and no pawn move in the last 50 moves of each player.
+func (g *Game) CanClaimFiftyMoveRule() bool {
+	return g.Board.HalfmoveClock >= 100
+}
+
+// Check if the player to move can claim a draw.
+func (g *Game) CanClaimDraw() bool {
+	return g.CanClaimThreefoldRepetition() || g.CanClaimFiftyMoveRule()
+}
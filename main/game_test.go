@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// A player giving the moves of a script, in SAN or "claim" for a draw claim.
+type scriptedPlayer struct {
+	moves []string
+}
+
+func (p *scriptedPlayer) GetMove(ctx context.Context, b *Board) (Move, error) {
+	san := p.moves[0]
+	p.moves = p.moves[1:]
+	if san == "claim" {
+		return ClaimDrawMove(b.State), nil
+	}
+	return b.ParseSAN(san)
+}
+
+func TestMoveRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		white  []string
+		black  []string
+		result GameResult
+	}{
+		{"50 move rule claimed", "4k3/8/8/8/8/8/8/R3K3 w - - 99 80", []string{"Ra2"}, []string{"claim"}, DrawBy(FiftyMoveRule)},
+		{"50 move rule not reached", "4k3/8/8/8/8/8/8/R3K3 w - - 98 80", []string{"Ra2"}, []string{"claim"}, GameResult{}},
+		{"75 move rule", "4k3/8/8/8/8/8/8/R3K3 w - - 149 80", []string{"Ra2"}, nil, DrawBy(SeventyFiveMoveRule)},
+		{"pawn move resets the count", "4k3/8/8/8/8/8/P7/R3K3 w - - 149 80", []string{"a3"}, []string{"claim"}, GameResult{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &Game{}
+			white, black := &scriptedPlayer{test.white}, &scriptedPlayer{test.black}
+			if err := g.InitWithFEN(test.fen, white, black); err != nil {
+				t.Fatal(err)
+			}
+			for len(white.moves)+len(black.moves) > 0 && !g.Play() {
+			}
+			if g.Result != test.result {
+				t.Errorf("result %v, want %v", g.Result, test.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import "fmt"
+
+// Game history: taking back moves, redoing them and jumping to any ply.
+// A ply is a move of one player, ply 0 is the starting position.
+
+// Play a move and record it in the history.
+func (g *Game) makeMove(move Move) {
+	player := g.Board.State
+	san := g.Board.MoveToSAN(move)
+	g.undos = append(g.undos, g.Board.MakeMove(move))
+	g.Moves = append(g.Moves, move)
+	g.Positions = append(g.Positions, g.Board.Hash)
+	g.emit(MoveEvent{player, move, san, len(g.Moves)})
+}
+
+// Get the number of moves played so far.
+func (g *Game) Ply() int {
+	return len(g.Moves)
+}
+
+// Take back the last move.
+// A finished game goes on again from the position before its last move,
+// Redo finishes it again.
+// Returns false if there is no move to take back.
+func (g *Game) Undo() bool {
+	if len(g.Moves) == 0 {
+		return false
+	}
+	last := len(g.Moves) - 1
+	move := g.Moves[last]
+	g.Board.UnmakeMove(g.undos[last])
+	g.redoMoves = append(g.redoMoves, move)
+	g.redoResults = append(g.redoResults, g.Result)
+	g.Moves = g.Moves[:last]
+	g.undos = g.undos[:last]
+	g.Positions = g.Positions[:last+1]
+	g.Result = GameResult{}
+	g.DrawOffer = 0
+	g.pendingMove = nil
+	g.emit(UndoEvent{move, g.Board.MoveToSAN(move), last})
+	return true
+}
+
+// Play the last move taken back again.
+// Returns false if there is no move to redo.
+// Playing any other move forgets the moves that could be redone.
+func (g *Game) Redo() bool {
+	if len(g.redoMoves) == 0 {
+		return false
+	}
+	last := len(g.redoMoves) - 1
+	move, result := g.redoMoves[last], g.redoResults[last]
+	g.redoMoves = g.redoMoves[:last]
+	g.redoResults = g.redoResults[:last]
+	g.makeMove(move)
+	g.Result = result
+	return true
+}
+
+// Go back or forward to the position after the given number of moves.
+func (g *Game) GoToPly(ply int) error {
+	if ply < 0 || ply > len(g.Moves)+len(g.redoMoves) {
+		return fmt.Errorf("ply %d is not in the game, it has %d moves", ply, len(g.Moves)+len(g.redoMoves))
+	}
+	for len(g.Moves) > ply {
+		g.Undo()
+	}
+	for len(g.Moves) < ply {
+		g.Redo()
+	}
+	return nil
+}
+
+// Take back the last move of the given player, and the opponent's reply if there is one,
+// so it is the player's turn again.
+// Returns false if the player has not moved yet.
+func (g *Game) TakeBack(player int) bool {
+	plies := g.takeBackPlies(player)
+	if plies == 0 {
+		return false
+	}
+	for i := 0; i < plies; i++ {
+		g.Undo()
+		// The player who made the move taken back is on move again
+		if g.Clock != nil {
+			g.Clock.TakeBack(g.Board.State)
+		}
+	}
+	return true
+}
+
+// Check if the given player has a move to take back.
+func (g *Game) CanTakeBack(player int) bool {
+	return g.takeBackPlies(player) > 0
+}
+
+// Get the number of moves to take back for the last move of the player, 0 if there is none.
+func (g *Game) takeBackPlies(player int) int {
+	// The player who made the last move
+	mover := 3 - g.Board.State
+	plies := 1
+	if mover != player {
+		plies = 2
+	}
+	if len(g.Moves) < plies {
+		return 0
+	}
+	return plies
+}
+
+// Get the position after the given number of moves.
+func (g *Game) PositionAt(ply int) (Board, error) {
+	if ply < 0 || ply > len(g.Moves) {
+		return Board{}, fmt.Errorf("ply %d is not in the game, it has %d moves", ply, len(g.Moves))
+	}
+	b := InitFEN(g.StartFEN)
+	for _, move := range g.Moves[:ply] {
+		b.MakeMove(move)
+	}
+	return b, nil
+}
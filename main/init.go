@@ -1,9 +1,18 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
 
 func main() {
 
+	// Divide the perft of a position with "go run ./main perft <depth> [fen]"
+	if len(os.Args) > 1 && os.Args[1] == "perft" {
+		perftCommand(os.Args[2:])
+		return
+	}
+
 	// remotePlayer := &RemotePlayer{}
 
 	// game := Game{}
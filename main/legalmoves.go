@@ -0,0 +1,147 @@
+package main
+
+// Legal move generation without trying the moves on the board.
+// The pieces giving check and the pinned pieces are found once per position,
+// then every piece only gets the moves that keep its own king safe:
+//   - in double check only the king can move,
+//   - in single check the other pieces must capture the checker or block the check,
+//   - a pinned piece can only move along the line between its king and the pinner,
+//   - the king cannot move to an attacked square, including squares behind it on a checking line.
+// En passant and Chess960 castling move two pieces at once and are checked against
+// the occupancy after the move.
+
+// Get all the legal moves of a player.
+func (b *Board) generateLegalMoves(player int) []Move {
+	pos := &b.Position
+	kingSq := pos.KingSquare(player)
+	if kingSq < 0 {
+		// Without a king every move is legal.
+		return b.generateMoves(player)
+	}
+	enemyPlayer := 3 - player
+	own := pos.Occupied[player-1]
+	enemy := pos.Occupied[enemyPlayer-1]
+	occupied := own | enemy
+	kingBit := Bitboard(1) << uint(kingSq)
+	kingLoc := SquareLocation(kingSq)
+
+	moves := make([]Move, 0, 48)
+
+	// King moves
+	// The king is taken off the board so it does not hide the squares behind it from a slider.
+	targets := kingAttacks[kingSq] &^ own
+	for targets != 0 {
+		to := targets.PopLSB()
+		if pos.attackersWith(to, enemyPlayer, occupied&^kingBit) != 0 {
+			continue
+		}
+		moveType := 'M'
+		if enemy.Has(to) {
+			moveType = 'C'
+		}
+		moves = append(moves, Move{moveType, 'K', false, kingLoc, SquareLocation(to)})
+	}
+
+	checkers := pos.AttackersOf(kingSq, enemyPlayer)
+	if checkers.Count() > 1 {
+		return moves
+	}
+
+	// The squares other pieces may move to: anywhere, or onto the checker or between it and the king.
+	checkMask := ^Bitboard(0)
+	if checkers != 0 {
+		checker := checkers
+		checkMask = checkers | betweenSquares[kingSq][checker.PopLSB()]
+	}
+
+	// Pinned pieces and the squares they may move to.
+	pinned := Bitboard(0)
+	var pinMask [64]Bitboard
+	enemyRooks := pos.PieceBitboard(enemyPlayer, 'R') | pos.PieceBitboard(enemyPlayer, 'Q')
+	enemyBishops := pos.PieceBitboard(enemyPlayer, 'B') | pos.PieceBitboard(enemyPlayer, 'Q')
+	pinners := rookAttacks(kingSq, enemy)&enemyRooks | bishopAttacks(kingSq, enemy)&enemyBishops
+	for pinners != 0 {
+		pinner := pinners.PopLSB()
+		between := betweenSquares[kingSq][pinner]
+		blockers := between & occupied
+		if blockers.Count() == 1 && blockers&own != 0 {
+			pinned |= blockers
+			pinMask[blockers.PopLSB()] = between | Bitboard(1)<<uint(pinner)
+		}
+	}
+
+	// Knights, bishops, rooks and queens
+	for _, pieceType := range "NBRQ" {
+		pieces := pos.PieceBitboard(player, pieceType)
+		for pieces != 0 {
+			from := pieces.PopLSB()
+			targets := pieceAttacks(pieceType, player, from, occupied) &^ own & checkMask
+			if pinned.Has(from) {
+				targets &= pinMask[from]
+			}
+			for targets != 0 {
+				to := targets.PopLSB()
+				moveType := 'M'
+				if enemy.Has(to) {
+					moveType = 'C'
+				}
+				moves = append(moves, Move{moveType, pieceType, false, SquareLocation(from), SquareLocation(to)})
+			}
+		}
+	}
+
+	// Pawns
+	// The pseudo-legal pawn moves are filtered with the same masks.
+	pawnMoves := b.appendPawnMoves(make([]Move, 0, 16), player)
+	for _, move := range pawnMoves {
+		from, to := SquareOf(move.From), SquareOf(move.To)
+		if move.Type == 'E' {
+			if b.enPassantIsLegal(move, player, kingSq) {
+				moves = append(moves, move)
+			}
+			continue
+		}
+		if !checkMask.Has(to) {
+			continue
+		}
+		if pinned.Has(from) && !pinMask[from].Has(to) {
+			continue
+		}
+		moves = append(moves, move)
+	}
+
+	// Castling
+	// The castling squares were checked for attacks with the castling rook still on its square.
+	// In Chess960 the rook can hide a slider on the first rank, so the king square is checked again.
+	if checkers == 0 {
+		for _, kingSide := range []bool{true, false} {
+			if !*b.castlingRight(player, kingSide) {
+				continue
+			}
+			move, ok := b.castlingMove(player, kingSide)
+			if !ok {
+				continue
+			}
+			rookFrom, rookTo := SquareOf(b.castlingRookLocation(move)), SquareOf(castlingRookTarget(move))
+			after := occupied &^ kingBit &^ (Bitboard(1) << uint(rookFrom))
+			after |= Bitboard(1)<<uint(SquareOf(move.To)) | Bitboard(1)<<uint(rookTo)
+			if pos.attackersWith(SquareOf(move.To), enemyPlayer, after) != 0 {
+				continue
+			}
+			moves = append(moves, move)
+		}
+	}
+
+	return moves
+}
+
+// Check if an en passant capture leaves the king safe.
+// Two pawns leave the same rank at once, which can open a line to the king.
+func (b *Board) enPassantIsLegal(move Move, player int, kingSq int) bool {
+	captured := Bitboard(1) << uint(SquareOf(Location{move.From.X, move.To.Y}))
+	after := b.Position.All()
+	after &^= Bitboard(1) << uint(SquareOf(move.From))
+	after &^= captured
+	after |= Bitboard(1) << uint(SquareOf(move.To))
+	return b.Position.attackersWith(kingSq, 3-player, after)&^captured == 0
+}
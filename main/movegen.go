@@ -0,0 +1,110 @@
+package main
+
+// Move generation on top of the bitboards in Board.Position.
+// The generated moves are the same Move values as PlayerPiece.GetMoves,
+// only much faster since no square has to be scanned.
+
+const promotionPieces = "QRBN"
+
+// Get all the pseudo-legal moves of a player from the bitboards.
+// This function does not check if the move will put the player in check.
+func (b *Board) generateMoves(player int) []Move {
+	moves := make([]Move, 0, 48)
+	pos := &b.Position
+	own := pos.Occupied[player-1]
+	enemy := pos.Occupied[2-player]
+	occupied := own | enemy
+
+	moves = b.appendPawnMoves(moves, player)
+
+	for _, pieceType := range "NBRQK" {
+		pieces := pos.PieceBitboard(player, pieceType)
+		for pieces != 0 {
+			from := pieces.PopLSB()
+			targets := pieceAttacks(pieceType, player, from, occupied) &^ own
+			for targets != 0 {
+				to := targets.PopLSB()
+				moveType := 'M'
+				if enemy.Has(to) {
+					moveType = 'C'
+				}
+				moves = append(moves, Move{moveType, pieceType, false, SquareLocation(from), SquareLocation(to)})
+			}
+		}
+	}
+
+	return b.appendCastlingMoves(moves, player)
+}
+
+// Append the pawn moves of a player.
+func (b *Board) appendPawnMoves(moves []Move, player int) []Move {
+	pos := &b.Position
+	enemy := pos.Occupied[2-player]
+	occupied := pos.All()
+
+	// White pawns move up the board, black pawns move down.
+	forward, startRank, lastRank := 1, 1, 6
+	if player == 2 {
+		forward, startRank, lastRank = -1, 6, 1
+	}
+
+	pawns := pos.PieceBitboard(player, 'P')
+	for pawns != 0 {
+		from := pawns.PopLSB()
+		fromLoc := SquareLocation(from)
+		if fromLoc.X+forward < 0 || fromLoc.X+forward > 7 {
+			continue
+		}
+
+		// Pushes
+		one := Location{fromLoc.X + forward, fromLoc.Y}
+		if !occupied.Has(SquareOf(one)) {
+			if fromLoc.X == lastRank {
+				moves = appendPromotions(moves, fromLoc, one)
+			} else {
+				moves = append(moves, Move{'M', 'P', false, fromLoc, one})
+				two := Location{fromLoc.X + 2*forward, fromLoc.Y}
+				if fromLoc.X == startRank && !occupied.Has(SquareOf(two)) {
+					moves = append(moves, Move{'M', 'P', false, fromLoc, two})
+				}
+			}
+		}
+
+		// Captures
+		captures := pawnAttacks[player-1][from] & enemy
+		for captures != 0 {
+			to := SquareLocation(captures.PopLSB())
+			if fromLoc.X == lastRank {
+				moves = appendPromotions(moves, fromLoc, to)
+			} else {
+				moves = append(moves, Move{'C', 'P', false, fromLoc, to})
+			}
+		}
+
+		// En passant
+		if target, ok := b.enPassantTarget(player); ok && pawnAttacks[player-1][from].Has(SquareOf(target)) {
+			moves = append(moves, Move{'E', 'P', false, fromLoc, target})
+		}
+	}
+	return moves
+}
+
+// Append the four promotion moves of a pawn.
+func appendPromotions(moves []Move, from Location, to Location) []Move {
+	for _, piece := range promotionPieces {
+		moves = append(moves, Move{'P', piece, false, from, to})
+	}
+	return moves
+}
+
+// Get the square a pawn of the given player can capture en passant on.
+// This is Board.EnPassantTargetSquare, set by MakeMove or InitFEN
+// right after the opponent moved a pawn two squares.
+func (b *Board) enPassantTarget(player int) (Location, bool) {
+	target := b.EnPassantTargetSquare
+	// White captures on the 6th rank, black on the 3rd.
+	if (player == 1 && target.X == 5) || (player == 2 && target.X == 2) {
+		return target, true
+	}
+	return Location{}, false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Perft (performance test) walks the tree of legal moves and counts the leaf nodes.
+// Comparing the counts with published values is the standard way to verify a move generator.
+
+// Count the leaf nodes of the legal move tree to the given depth.
+func (b *Board) Perft(depth int) int {
+	return b.perft(depth, (*Board).GetPlayerLegalMoves)
+}
+
+// Perft with the legal moves found by GetPlayerLegalMovesByTrial.
+// Used to check that both generators agree.
+func (b *Board) PerftByTrial(depth int) int {
+	return b.perft(depth, (*Board).GetPlayerLegalMovesByTrial)
+}
+
+func (b *Board) perft(depth int, legalMoves func(*Board, int) []Move) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := legalMoves(b, b.State)
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, move := range moves {
+		undo := b.MakeMove(move)
+		nodes += b.perft(depth-1, legalMoves)
+		b.UnmakeMove(undo)
+	}
+	return nodes
+}
+
+// Perft broken down per root move.
+// Returns the number of leaf nodes below each legal move, keyed by the move in UCI notation.
+func (b *Board) Divide(depth int) map[string]int {
+	result := make(map[string]int)
+	if depth < 1 {
+		return result
+	}
+	for _, move := range b.GetPlayerLegalMoves(b.State) {
+		undo := b.MakeMove(move)
+		result[move.UCI()] = b.Perft(depth - 1)
+		b.UnmakeMove(undo)
+	}
+	return result
+}
+
+// Print the divide of a position, one line per root move.
+func PrintDivide(b *Board, depth int) {
+	divide := b.Divide(depth)
+	moves := make([]string, 0, len(divide))
+	for move := range divide {
+		moves = append(moves, move)
+	}
+	sort.Strings(moves)
+
+	total := 0
+	for _, move := range moves {
+		fmt.Printf("%s: %d\n", move, divide[move])
+		total += divide[move]
+	}
+	fmt.Printf("\nMoves: %d\nNodes: %d\n", len(moves), total)
+}
+
+// Handle the perft command line:
+//
+//	perft <depth> [fen]     print the divide of a position (default: initial position)
+//
+// The node counts of the standard positions are checked by TestPerft.
+func perftCommand(args []string) {
+	var depth int
+	var err error
+	if len(args) > 0 {
+		depth, err = strconv.Atoi(args[0])
+	}
+	if len(args) == 0 || err != nil || depth < 1 {
+		fmt.Println("Usage: perft <depth> [fen]")
+		os.Exit(2)
+	}
+	var b Board
+	if len(args) > 1 {
+		b, err = ParseFEN(strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	} else {
+		b.Init()
+	}
+	PrintDivide(&b, depth)
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+type perftPosition struct {
+	Name string
+	FEN  string
+	// Published node counts, Nodes[i] is the count at depth i+1.
+	Nodes []int
+}
+
+// Standard perft positions and their known node counts.
+// See https://www.chessprogramming.org/Perft_Results
+var perftSuite = []perftPosition{
+	{"Initial position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		[]int{20, 400, 8902, 197281, 4865609}},
+	{"Kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		[]int{48, 2039, 97862, 4085603}},
+	{"En passant and pins", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		[]int{14, 191, 2812, 43238, 674624}},
+	{"Promotions and castling", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		[]int{6, 264, 9467, 422333}},
+	{"Promotions and castling (mirrored)", "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+		[]int{6, 264, 9467, 422333}},
+	{"Discovered checks", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		[]int{44, 1486, 62379, 2103487}},
+	{"Middle game", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		[]int{46, 2079, 89890, 3894594}},
+	{"Chess960 (1)", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		[]int{21, 528, 12189, 326672}},
+	{"Chess960 (2)", "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+		[]int{21, 807, 18002, 667366}},
+	{"Chess960 (3)", "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9",
+		[]int{20, 479, 10471, 273318}},
+}
+
+// Check the node counts of the perft positions with both move generators.
+// With -short only the shallow depths are searched.
+func TestPerft(t *testing.T) {
+	maxNodes := 5000000
+	if testing.Short() {
+		maxNodes = 10000
+	}
+	for _, position := range perftSuite {
+		t.Run(position.Name, func(t *testing.T) {
+			b, err := ParseFEN(position.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for depth, expected := range position.Nodes {
+				if expected > maxNodes {
+					break
+				}
+				if nodes := b.Perft(depth + 1); nodes != expected {
+					t.Errorf("depth %d: Perft = %d, want %d", depth+1, nodes, expected)
+				}
+				if nodes := b.PerftByTrial(depth + 1); nodes != expected {
+					t.Errorf("depth %d: PerftByTrial = %d, want %d", depth+1, nodes, expected)
+				}
+			}
+		})
+	}
+}
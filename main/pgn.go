@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Portable Game Notation (PGN) export.
+// A game is written as tag pairs followed by the moves in SAN, e.g.
+//
+//	[Event "?"]
+//	...
+//	[Result "1-0"]
+//
+//	1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0
+
+// The Seven Tag Roster, written first and in this order.
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// Movetext lines are wrapped so they are not longer than this.
+const pgnLineLength = 79
+
+// Set a PGN tag of the game, e.g. "Event", "White" or "Date".
+// The Result, SetUp, FEN and Termination tags are taken from the game itself.
+func (g *Game) SetTag(name string, value string) {
+	if g.Tags == nil {
+		g.Tags = make(map[string]string)
+	}
+	g.Tags[name] = value
+}
+
+// Get the PGN result token of the game, "*" while it is in progress.
+func (g *Game) ResultToken() string {
+	return g.Result.Token()
+}
+
+// Get the tags of the game in the order they are written.
+func (g *Game) pgnTags() [][2]string {
+	tags := [][2]string{}
+	for _, name := range sevenTagRoster {
+		value, ok := g.Tags[name]
+		switch {
+		case name == "Result":
+			value = g.ResultToken()
+		case ok:
+		case name == "Date":
+			value = "????.??.??"
+		default:
+			value = "?"
+		}
+		tags = append(tags, [2]string{name, value})
+	}
+
+	// The starting position of games that do not start from the classical setup
+	start := InitFEN(g.StartFEN)
+	if start.Chess960 {
+		tags = append(tags, [2]string{"Variant", "Chess960"})
+	}
+	if g.StartFEN != startFEN {
+		tags = append(tags, [2]string{"SetUp", "1"}, [2]string{"FEN", g.StartFEN})
+	}
+
+	timeControl, ok := g.Tags["TimeControl"]
+	if !ok {
+		timeControl = "-"
+	}
+	tags = append(tags, [2]string{"TimeControl", timeControl})
+
+	tags = append(tags, [2]string{"Termination", g.Result.PGNTermination()})
+
+	// Any other tags in alphabetical order
+	names := []string{}
+	for name := range g.Tags {
+		switch name {
+		case "Event", "Site", "Date", "Round", "White", "Black", "Result",
+			"Variant", "SetUp", "FEN", "TimeControl", "Termination":
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tags = append(tags, [2]string{name, g.Tags[name]})
+	}
+	return tags
+}
+
+// Get the moves of the game in SAN with their move numbers, e.g. "1.", "e4", "e5", "2.", ...
+func (g *Game) pgnMoveTokens() []string {
+	b := InitFEN(g.StartFEN)
+	tokens := []string{}
+	for i, move := range g.Moves {
+		if b.State == 1 {
+			tokens = append(tokens, fmt.Sprintf("%d.", b.FullmoveNumber))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", b.FullmoveNumber))
+		}
+		tokens = append(tokens, b.MoveToSAN(move))
+		b.MakeMove(move)
+	}
+	return append(tokens, g.ResultToken())
+}
+
+// Write the game as PGN.
+func (g *Game) WritePGN(w io.Writer) error {
+	var sb strings.Builder
+	for _, tag := range g.pgnTags() {
+		value := strings.ReplaceAll(tag[1], `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag[0], value)
+	}
+	sb.WriteString("\n")
+
+	// Wrap the movetext between tokens
+	line := ""
+	for _, token := range g.pgnMoveTokens() {
+		if line != "" && len(line)+1+len(token) > pgnLineLength {
+			sb.WriteString(line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += token
+	}
+	sb.WriteString(line + "\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Get the game as PGN.
+func (g *Game) PGN() string {
+	var sb strings.Builder
+	g.WritePGN(&sb)
+	return sb.String()
+}
+
+// Write the PGN of the game to w when it is over, e.g. to keep the finished games in a file.
+// Every game is followed by a blank line, so the file can hold many games.
+// Returns a function that stops recording.
+func (g *Game) RecordPGN(w io.Writer) func() {
+	return g.Subscribe(func(event GameEvent) {
+		if _, ok := event.(GameOverEvent); !ok {
+			return
+		}
+		err := g.WritePGN(w)
+		if err == nil {
+			_, err = io.WriteString(w, "\n")
+		}
+		if err != nil {
+			fmt.Println("Could not record the game:", err)
+		}
+	})
+}
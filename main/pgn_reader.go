@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Streaming PGN reader.
+// Games are read one at a time, so files with many games never have to be in memory at once:
+//
+//	reader := NewPGNReader(file)
+//	for {
+//		game, err := reader.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+//
+// The mainline of every game is replayed on a Board while it is read.
+// Variations are kept as text and are not replayed.
+
+// A game read from a PGN file.
+type PGNGame struct {
+	// Tag pairs, and their names in the order they appeared.
+	Tags     map[string]string
+	TagNames []string
+
+	// The mainline moves, as written in the file and as moves of the board.
+	SAN   []string
+	Moves []Move
+
+	// The positions of the mainline, Positions[0] is the starting position
+	// and Positions[i] the position after Moves[i-1].
+	Positions []Board
+
+	// Comments and NAGs ($1, $2...) keyed by the number of mainline moves played before them.
+	Comments map[int][]string
+	NAGs     map[int][]int
+
+	// Variations keyed by the index of the mainline move they replace, as text without the parentheses.
+	Variations map[int][]string
+
+	// Result token of the movetext: "1-0", "0-1", "1/2-1/2" or "*".
+	Result string
+}
+
+// Get the position at the end of the mainline.
+func (g *PGNGame) Board() *Board {
+	return &g.Positions[len(g.Positions)-1]
+}
+
+// Error in a PGN file, with the place it happened.
+type PGNError struct {
+	Game    int // 1 for the first game of the file
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *PGNError) Error() string {
+	return fmt.Sprintf("pgn: game %d, line %d, column %d: %s", e.Game, e.Line, e.Column, e.Message)
+}
+
+type pgnTokenKind int
+
+const (
+	pgnTag pgnTokenKind = iota
+	pgnMove
+	pgnComment
+	pgnNAG
+	pgnOpenVariation
+	pgnCloseVariation
+	pgnResult
+)
+
+type pgnToken struct {
+	kind   pgnTokenKind
+	text   string // move, comment, NAG number, result or tag name
+	value  string // tag value
+	line   int
+	column int
+}
+
+// Reads the games of a PGN file one at a time.
+type PGNReader struct {
+	r *bufio.Reader
+
+	// Position of the next rune, and of the last one read so it can be unread.
+	line, column         int
+	lastLine, lastColumn int
+
+	// Number of games started so far
+	games int
+
+	// A tag that started the next game before the previous one had a result
+	pending *pgnToken
+}
+
+// Create a PGN reader.
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{r: bufio.NewReader(r), line: 1, column: 1}
+}
+
+// Read a PGN file with one game or more.
+func ReadPGN(r io.Reader) ([]*PGNGame, error) {
+	reader := NewPGNReader(r)
+	games := []*PGNGame{}
+	for {
+		game, err := reader.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+}
+
+// Read the next game.
+// Returns io.EOF when there are no more games.
+// A game with an error, e.g. an illegal move or a stray ')', is returned up to the error together with a *PGNError.
+// The rest of its movetext is skipped, so the reader can go on with the next game.
+func (p *PGNReader) Next() (*PGNGame, error) {
+	var game *PGNGame
+	var board Board
+	var gameErr error
+	depth := 0
+	var variation []string
+
+	// Keep the first error of the game
+	fail := func(err error) {
+		if gameErr == nil {
+			gameErr = err
+		}
+	}
+
+	// Set up the board when the movetext starts, with the first token that is not a tag.
+	startMovetext := func(line int, column int) {
+		board.Init()
+		if fen, ok := game.Tags["FEN"]; ok {
+			start, err := ParseFEN(fen)
+			if err != nil {
+				fail(p.errorAt(line, column, "bad FEN tag: "+err.Error()))
+			} else {
+				board = start
+			}
+		}
+		game.Positions = []Board{board.Copy()}
+	}
+
+	for {
+		token, err := p.nextToken()
+		if err == io.EOF {
+			if game == nil {
+				return nil, io.EOF
+			}
+			if game.Positions == nil {
+				startMovetext(p.line, p.column)
+			}
+			if depth > 0 {
+				fail(p.errorAt(p.line, p.column, "unterminated variation"))
+			}
+			return game, gameErr
+		}
+
+		if game == nil {
+			p.games++
+			game = &PGNGame{
+				Tags:       make(map[string]string),
+				Comments:   make(map[int][]string),
+				NAGs:       make(map[int][]int),
+				Variations: make(map[int][]string),
+			}
+		}
+
+		// The token could not be read, skip it
+		if err != nil {
+			if pgnErr, ok := err.(*PGNError); ok {
+				// The error was made before the game started
+				pgnErr.Game = p.games
+			}
+			fail(err)
+			continue
+		}
+
+		if token.kind != pgnTag && game.Positions == nil {
+			startMovetext(token.line, token.column)
+		}
+
+		// Inside a variation everything is kept as text.
+		if depth > 0 {
+			switch token.kind {
+			case pgnOpenVariation:
+				depth++
+				variation = append(variation, "(")
+			case pgnCloseVariation:
+				depth--
+				if depth == 0 {
+					text := strings.Join(variation, " ")
+					text = strings.ReplaceAll(strings.ReplaceAll(text, "( ", "("), " )", ")")
+					ply := len(game.Moves) - 1
+					game.Variations[ply] = append(game.Variations[ply], text)
+					continue
+				}
+				variation = append(variation, ")")
+			case pgnComment:
+				variation = append(variation, "{"+token.text+"}")
+			case pgnNAG:
+				variation = append(variation, "$"+token.text)
+			case pgnTag:
+				// The variation was never closed, the tag starts the next game
+				fail(p.errorAt(token.line, token.column, "tag inside a variation"))
+				p.pending = &token
+				return game, gameErr
+			default:
+				variation = append(variation, token.text)
+			}
+			continue
+		}
+
+		switch token.kind {
+		case pgnTag:
+			if game.Positions != nil {
+				// A new game started without a result token
+				p.pending = &token
+				return game, gameErr
+			}
+			if _, ok := game.Tags[token.text]; !ok {
+				game.TagNames = append(game.TagNames, token.text)
+			}
+			game.Tags[token.text] = token.value
+
+		case pgnMove:
+			if gameErr != nil {
+				continue
+			}
+			move, err := board.ParseSAN(token.text)
+			if err != nil {
+				fail(p.errorAt(token.line, token.column, fmt.Sprintf("move %d: %v", board.FullmoveNumber, err)))
+				continue
+			}
+			board.MakeMove(move)
+			game.SAN = append(game.SAN, token.text)
+			game.Moves = append(game.Moves, move)
+			game.Positions = append(game.Positions, board.Copy())
+
+		case pgnComment:
+			game.Comments[len(game.Moves)] = append(game.Comments[len(game.Moves)], token.text)
+
+		case pgnNAG:
+			nag := 0
+			fmt.Sscanf(token.text, "%d", &nag)
+			game.NAGs[len(game.Moves)] = append(game.NAGs[len(game.Moves)], nag)
+
+		case pgnOpenVariation:
+			if len(game.Moves) == 0 {
+				fail(p.errorAt(token.line, token.column, "variation before the first move"))
+			}
+			depth = 1
+			variation = nil
+
+		case pgnCloseVariation:
+			fail(p.errorAt(token.line, token.column, "unexpected ')'"))
+
+		case pgnResult:
+			game.Result = token.text
+			return game, gameErr
+		}
+	}
+}
+
+// Create an error at the given place of the current game.
+func (p *PGNReader) errorAt(line int, column int, message string) *PGNError {
+	return &PGNError{p.games, line, column, message}
+}
+
+// Read a rune, keeping track of its position.
+func (p *PGNReader) read() (rune, error) {
+	char, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	p.lastLine, p.lastColumn = p.line, p.column
+	if char == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+	return char, nil
+}
+
+// Put back the last rune read.
+func (p *PGNReader) unread() {
+	p.r.UnreadRune()
+	p.line, p.column = p.lastLine, p.lastColumn
+}
+
+func isPGNSpace(char rune) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+}
+
+// Read the next token, skipping white space, move numbers and escaped lines.
+func (p *PGNReader) nextToken() (pgnToken, error) {
+	if p.pending != nil {
+		token := *p.pending
+		p.pending = nil
+		return token, nil
+	}
+
+	for {
+		char, err := p.read()
+		if err != nil {
+			return pgnToken{}, err
+		}
+		if isPGNSpace(char) {
+			continue
+		}
+		token := pgnToken{line: p.lastLine, column: p.lastColumn}
+
+		switch char {
+		case '%':
+			// Escaped line
+			if token.column == 1 {
+				p.readUntil('\n')
+				continue
+			}
+			return token, p.errorAt(token.line, token.column, "unexpected '%'")
+
+		case '[':
+			return p.readTag(token)
+
+		case '{':
+			text, ok := p.readUntil('}')
+			if !ok {
+				return token, p.errorAt(token.line, token.column, "unterminated comment")
+			}
+			token.kind, token.text = pgnComment, strings.TrimSpace(text)
+			return token, nil
+
+		case ';':
+			text, _ := p.readUntil('\n')
+			token.kind, token.text = pgnComment, strings.TrimSpace(text)
+			return token, nil
+
+		case '(':
+			token.kind = pgnOpenVariation
+			return token, nil
+
+		case ')':
+			token.kind = pgnCloseVariation
+			return token, nil
+
+		case '$':
+			token.kind, token.text = pgnNAG, p.readSymbol()
+			if token.text == "" || strings.Trim(token.text, "0123456789") != "" {
+				return token, p.errorAt(token.line, token.column, "bad NAG $"+token.text)
+			}
+			return token, nil
+
+		case ']', '}', '"':
+			return token, p.errorAt(token.line, token.column, fmt.Sprintf("unexpected '%c'", char))
+		}
+
+		p.unread()
+		symbol := p.readSymbol()
+		switch symbol {
+		case "1-0", "0-1", "1/2-1/2", "*":
+			token.kind, token.text = pgnResult, symbol
+			return token, nil
+		}
+
+		// Move numbers such as "12." or "12...", possibly glued to the move: "12.e4"
+		if strings.Trim(symbol, ".") == "" {
+			continue
+		}
+		digits := strings.TrimLeft(symbol, "0123456789")
+		if digits != symbol && (digits == "" || digits[0] == '.') {
+			move := strings.TrimLeft(digits, ".")
+			if move == "" {
+				continue
+			}
+			token.column += len(symbol) - len(move)
+			symbol = move
+		}
+		token.kind, token.text = pgnMove, symbol
+		return token, nil
+	}
+}
+
+// Read until the given rune, which is consumed but not returned.
+// Returns false if the end of the file came first.
+func (p *PGNReader) readUntil(end rune) (string, bool) {
+	var sb strings.Builder
+	for {
+		char, err := p.read()
+		if err != nil {
+			return sb.String(), false
+		}
+		if char == end {
+			return sb.String(), true
+		}
+		sb.WriteRune(char)
+	}
+}
+
+// Read a symbol: everything up to white space or a character with a meaning of its own.
+func (p *PGNReader) readSymbol() string {
+	var sb strings.Builder
+	for {
+		char, err := p.read()
+		if err != nil {
+			return sb.String()
+		}
+		if isPGNSpace(char) || strings.ContainsRune("[]{}();$\"", char) {
+			p.unread()
+			return sb.String()
+		}
+		sb.WriteRune(char)
+	}
+}
+
+// Read a tag pair after its '[', e.g. [White "Fischer, Robert J."]
+func (p *PGNReader) readTag(token pgnToken) (pgnToken, error) {
+	p.skipSpace()
+	name := p.readSymbol()
+	if name == "" {
+		return token, p.errorAt(p.line, p.column, "missing tag name")
+	}
+	p.skipSpace()
+	char, err := p.read()
+	if err != nil || char != '"' {
+		return token, p.errorAt(p.lastLine, p.lastColumn, "missing tag value")
+	}
+
+	var value strings.Builder
+	for {
+		char, err := p.read()
+		if err != nil || char == '\n' {
+			return token, p.errorAt(token.line, token.column, "unterminated tag value")
+		}
+		if char == '"' {
+			break
+		}
+		if char == '\\' {
+			char, err = p.read()
+			if err != nil {
+				return token, p.errorAt(token.line, token.column, "unterminated tag value")
+			}
+		}
+		value.WriteRune(char)
+	}
+
+	p.skipSpace()
+	char, err = p.read()
+	if err != nil || char != ']' {
+		return token, p.errorAt(p.lastLine, p.lastColumn, "missing ']' after tag")
+	}
+	token.kind, token.text, token.value = pgnTag, name, value.String()
+	return token, nil
+}
+
+// Skip white space.
+func (p *PGNReader) skipSpace() {
+	for {
+		char, err := p.read()
+		if err != nil {
+			return
+		}
+		if !isPGNSpace(char) {
+			p.unread()
+			return
+		}
+	}
+}
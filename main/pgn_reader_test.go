@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPGN(t *testing.T) {
+	pgn := `% an escaped line
+[Event "Test \"quoted\""]
+[White "Alice"]
+[Black "Bob"]
+[Result "1-0"]
+
+1.e4 {best by test} e5 $1 2. Nf3 (2. f4 exf4 (2... d5) 3. Nf3) 2... Nc6 ; to the end of the line
+3. Bb5 a6 $2 $13 1-0
+`
+	games, err := ReadPGN(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("read %d games, want 1", len(games))
+	}
+	game := games[0]
+
+	if want := []string{"Event", "White", "Black", "Result"}; !reflect.DeepEqual(game.TagNames, want) {
+		t.Errorf("TagNames = %v, want %v", game.TagNames, want)
+	}
+	if game.Tags["Event"] != `Test "quoted"` {
+		t.Errorf("Event tag = %q", game.Tags["Event"])
+	}
+	if want := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6"}; !reflect.DeepEqual(game.SAN, want) {
+		t.Errorf("SAN = %v, want %v", game.SAN, want)
+	}
+	if len(game.Moves) != 6 || len(game.Positions) != 7 {
+		t.Errorf("got %d moves and %d positions, want 6 and 7", len(game.Moves), len(game.Positions))
+	}
+	if want := "r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 4"; game.Board().ToFEN() != want {
+		t.Errorf("final position %s, want %s", game.Board().ToFEN(), want)
+	}
+	if want := map[int][]string{1: {"best by test"}, 4: {"to the end of the line"}}; !reflect.DeepEqual(game.Comments, want) {
+		t.Errorf("Comments = %v, want %v", game.Comments, want)
+	}
+	if want := map[int][]int{2: {1}, 6: {2, 13}}; !reflect.DeepEqual(game.NAGs, want) {
+		t.Errorf("NAGs = %v, want %v", game.NAGs, want)
+	}
+	if want := map[int][]string{2: {"f4 exf4 (d5) Nf3"}}; !reflect.DeepEqual(game.Variations, want) {
+		t.Errorf("Variations = %v, want %v", game.Variations, want)
+	}
+	if game.Result != "1-0" {
+		t.Errorf("Result = %q, want 1-0", game.Result)
+	}
+}
+
+// Games are read one at a time, a game without a result ends at the tags of the next one.
+func TestReadPGNMultipleGames(t *testing.T) {
+	pgn := `[Event "First"]
+
+1. e4 e5 1/2-1/2
+
+[Event "Second"]
+[FEN "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1"]
+
+1. O-O-O Kf7
+
+[Event "Third"]
+
+*
+`
+	reader := NewPGNReader(strings.NewReader(pgn))
+	want := []struct {
+		event  string
+		moves  int
+		result string
+		fen    string
+	}{
+		{"First", 2, "1/2-1/2", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"},
+		{"Second", 2, "", "8/5k2/8/8/8/8/8/2KR4 w - - 2 2"},
+		{"Third", 0, "*", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+	}
+	for i, w := range want {
+		game, err := reader.Next()
+		if err != nil {
+			t.Fatalf("game %d: %v", i+1, err)
+		}
+		if game.Tags["Event"] != w.event || len(game.Moves) != w.moves || game.Result != w.result || game.Board().ToFEN() != w.fen {
+			t.Errorf("game %d: event %q, %d moves, result %q, position %s, want %q, %d, %q, %s",
+				i+1, game.Tags["Event"], len(game.Moves), game.Result, game.Board().ToFEN(), w.event, w.moves, w.result, w.fen)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() after the last game = %v, want io.EOF", err)
+	}
+}
+
+// A malformed game is returned up to its error, with the place of the error,
+// and the reader goes on with the next game.
+func TestReadPGNErrors(t *testing.T) {
+	next := "\n[Event \"Next\"]\n\n1. d4 d5 *\n"
+	tests := []struct {
+		name    string
+		pgn     string
+		moves   int
+		line    int
+		column  int
+		message string
+	}{
+		{"illegal move", "[Event \"Bad\"]\n\n1. e4 e5 2. Ke3 Nc6 3. Nf3 1-0\n", 2, 3, 13, "move 2: illegal move"},
+		{"ambiguous move", "[Event \"Bad\"]\n[FEN \"4k3/8/8/8/8/8/4K3/R6R w - - 0 1\"]\n\n1. Rd1 Kd7 1-0\n", 0, 4, 4, "ambiguous move"},
+		{"stray parenthesis", "[Event \"Bad\"]\n\n1. e4 ) e5 2. Nf3 *\n", 1, 3, 7, "unexpected ')'"},
+		{"unexpected brace", "[Event \"Bad\"]\n\n1. e4 e5 } 2. Nf3 Nc6 *\n", 2, 3, 10, "unexpected '}'"},
+		{"bad NAG", "[Event \"Bad\"]\n\n1. e4 $x e5 *\n", 1, 3, 7, "bad NAG $x"},
+		{"bad FEN tag", "[Event \"Bad\"]\n[FEN \"8/8/8 w - - 0 1\"]\n\n1. e4 *\n", 0, 4, 4, "bad FEN tag"},
+		{"variation before the first move", "[Event \"Bad\"]\n\n(1. d4) 1. e4 *\n", 0, 3, 1, "variation before the first move"},
+		{"tag inside a variation", "[Event \"Bad\"]\n\n1. e4 (1. d4 d5\n", 1, 5, 1, "tag inside a variation"},
+		{"missing tag value", "[Event]\n\n1. e4 *\n", 0, 1, 7, "missing tag value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewPGNReader(strings.NewReader(test.pgn + next))
+			game, err := reader.Next()
+			pgnErr, ok := err.(*PGNError)
+			if !ok {
+				t.Fatalf("Next() = %v, want a *PGNError", err)
+			}
+			if pgnErr.Game != 1 || pgnErr.Line != test.line || pgnErr.Column != test.column || !strings.Contains(pgnErr.Message, test.message) {
+				t.Errorf("error %q, want game 1, line %d, column %d: %s", pgnErr, test.line, test.column, test.message)
+			}
+			if game == nil {
+				t.Fatal("no game returned with the error")
+			}
+			if len(game.Moves) != test.moves {
+				t.Fatalf("game read up to the error has %d moves, want %d", len(game.Moves), test.moves)
+			}
+
+			game, err = reader.Next()
+			if err != nil {
+				t.Fatalf("next game: %v", err)
+			}
+			if game.Tags["Event"] != "Next" || len(game.Moves) != 2 || game.Result != "*" {
+				t.Errorf("next game: event %q, %d moves, result %q", game.Tags["Event"], len(game.Moves), game.Result)
+			}
+			if _, err := reader.Next(); err != io.EOF {
+				t.Errorf("Next() after the last game = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+// Errors found before the movetext count for the game they are in.
+func TestReadPGNErrorGameNumber(t *testing.T) {
+	pgn := "[Event \"First\"]\n\n1. e4 *\n\n[Event \"Second\"]\n1. e4 e5 2. Qxf7 *\n\n[Event \"Third\"]\n} 1. d4 *\n"
+	games, err := ReadPGN(strings.NewReader(pgn))
+	pgnErr, ok := err.(*PGNError)
+	if !ok || pgnErr.Game != 2 || pgnErr.Line != 6 || pgnErr.Column != 13 {
+		t.Fatalf("ReadPGN() error = %v, want game 2, line 6, column 13", err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("ReadPGN() returned %d games before the error, want 1", len(games))
+	}
+
+	reader := NewPGNReader(strings.NewReader(pgn))
+	for i := 1; i <= 3; i++ {
+		_, err := reader.Next()
+		if i == 1 && err != nil {
+			t.Errorf("game 1: %v", err)
+		}
+		if i > 1 {
+			if pgnErr, ok := err.(*PGNError); !ok || pgnErr.Game != i {
+				t.Errorf("game %d: error %v, want an error of game %d", i, err, i)
+			}
+		}
+	}
+}
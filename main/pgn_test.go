@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Play moves given in SAN, separated by spaces, on the game.
+func playSAN(t *testing.T, g *Game, moves string) {
+	t.Helper()
+	for _, san := range strings.Fields(moves) {
+		move, err := g.Board.ParseSAN(san)
+		if err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+		g.makeMove(move)
+	}
+}
+
+func TestWritePGN(t *testing.T) {
+	g := &Game{}
+	g.Init(nil, nil)
+	playSAN(t, g, "e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#")
+	g.Result = Win(1, Checkmate)
+	g.SetTag("White", "Alice")
+	g.SetTag("Black", `Bob "the rook"`)
+	g.SetTag("Opening", "Scholar's mate")
+	g.SetTag("Annotator", "Carol")
+
+	want := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "Alice"]
+[Black "Bob \"the rook\""]
+[Result "1-0"]
+[TimeControl "-"]
+[Termination "normal"]
+[Annotator "Carol"]
+[Opening "Scholar's mate"]
+
+1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0
+`
+	if got := g.PGN(); got != want {
+		t.Errorf("PGN() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWritePGNInProgress(t *testing.T) {
+	g := &Game{}
+	g.Init(nil, nil)
+	playSAN(t, g, "d4 d5")
+	g.SetTimeControl(TimeControlWithBonus(5*time.Minute, 3*time.Second, Increment))
+	pgn := g.PGN()
+	for _, line := range []string{`[Result "*"]`, `[TimeControl "300+3"]`, `[Termination "unterminated"]`, "1. d4 d5 *"} {
+		if !strings.Contains(pgn, line+"\n") {
+			t.Errorf("PGN() has no line %q:\n%s", line, pgn)
+		}
+	}
+}
+
+// A game from a FEN gets the SetUp and FEN tags, and its first move number
+// is taken from the position, with "..." when black moves first.
+func TestWritePGNFromFEN(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/4P3/4K2R b K - 3 20"
+	g := &Game{}
+	if err := g.InitWithFEN(fen, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	playSAN(t, g, "Kd7 O-O Kc6")
+	g.Result = DrawBy(Agreement)
+	pgn := g.PGN()
+	for _, line := range []string{`[SetUp "1"]`, `[FEN "` + fen + `"]`, `[Result "1/2-1/2"]`, "20... Kd7 21. O-O Kc6 1/2-1/2"} {
+		if !strings.Contains(pgn, line+"\n") {
+			t.Errorf("PGN() has no line %q:\n%s", line, pgn)
+		}
+	}
+}
+
+func TestWritePGNChess960(t *testing.T) {
+	g := &Game{}
+	if err := g.InitChess960(0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	pgn := g.PGN()
+	for _, line := range []string{`[Variant "Chess960"]`, `[SetUp "1"]`, `[FEN "` + g.StartFEN + `"]`} {
+		if !strings.Contains(pgn, line+"\n") {
+			t.Errorf("PGN() has no line %q:\n%s", line, pgn)
+		}
+	}
+}
+
+// Long movetext is wrapped between moves, no line is longer than 79 characters.
+func TestWritePGNWrapsLines(t *testing.T) {
+	g := &Game{}
+	g.Init(nil, nil)
+	for i := 0; i < 10; i++ {
+		playSAN(t, g, "Nf3 Nf6 Ng1 Ng8")
+	}
+	pgn := g.PGN()
+	movetext := pgn[strings.Index(pgn, "\n\n")+2:]
+	lines := strings.Split(strings.TrimSuffix(movetext, "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("movetext is not wrapped:\n%s", movetext)
+	}
+	for _, line := range lines {
+		if len(line) > pgnLineLength {
+			t.Errorf("line longer than %d characters: %q", pgnLineLength, line)
+		}
+		if strings.HasPrefix(line, " ") || strings.HasSuffix(line, " ") {
+			t.Errorf("line with a leading or trailing space: %q", line)
+		}
+	}
+	if got := strings.Join(lines, " "); !strings.HasPrefix(got, "1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3") || !strings.HasSuffix(got, "20. Ng1 Ng8 *") {
+		t.Errorf("movetext changed by the wrapping: %s", got)
+	}
+}
+
+// A written game is read back with the same tags, moves and result.
+func TestWritePGNRoundTrip(t *testing.T) {
+	g := &Game{}
+	if err := g.InitWithFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	playSAN(t, g, "O-O O-O-O Rfe1 Rhe8 Rxe8 Rxe8")
+	g.Result = Win(2, Resignation)
+	g.SetTag("Event", "Round trip")
+
+	games, err := ReadPGN(strings.NewReader(g.PGN()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("read %d games, want 1", len(games))
+	}
+	read := games[0]
+	if read.Result != "0-1" || read.Tags["Result"] != "0-1" || read.Tags["Event"] != "Round trip" || read.Tags["FEN"] != g.StartFEN {
+		t.Errorf("tags not read back: %v, result %q", read.Tags, read.Result)
+	}
+	if len(read.Moves) != len(g.Moves) {
+		t.Fatalf("read %d moves, want %d", len(read.Moves), len(g.Moves))
+	}
+	for i := range g.Moves {
+		if read.Moves[i] != g.Moves[i] {
+			t.Errorf("move %d: read %s, want %s", i+1, read.Moves[i].ToString(), g.Moves[i].ToString())
+		}
+	}
+	if read.Board().ToFEN() != g.Board.ToFEN() {
+		t.Errorf("final position %s, want %s", read.Board().ToFEN(), g.Board.ToFEN())
+	}
+}
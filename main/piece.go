@@ -108,55 +108,54 @@ func (p PlayerPiece) GetPawnMoves(b *Board) []Move {
 		}
 
 		// A pawn can move one space diagonally forward to capture an enemy piece.
-		if p.Location.X < 6 {
+		if p.Location.X < 7 {
 			// left
 			if p.Location.Y > 0 {
 				leftPiece := b.GetPiece(p.Location.X+1, p.Location.Y-1)
 				if !leftPiece.IsEmpty() && leftPiece.GetPlayer() != p.Player {
-					moves = append(moves, Move{
-						Type:             'C',
-						Piece:            'P',
-						IsDisambiguation: false,
-						From:             p.Location,
-						To:               Location{p.Location.X + 1, p.Location.Y - 1},
-					})
+					// check if the pawn can be promoted
+					if p.Location.X == 6 {
+						moves = appendPromotions(moves, p.Location, Location{p.Location.X + 1, p.Location.Y - 1})
+					} else {
+						moves = append(moves, Move{
+							Type:             'C',
+							Piece:            'P',
+							IsDisambiguation: false,
+							From:             p.Location,
+							To:               Location{p.Location.X + 1, p.Location.Y - 1},
+						})
+					}
 				}
 			}
 			// right
 			if p.Location.Y < 7 {
 				rightPiece := b.GetPiece(p.Location.X+1, p.Location.Y+1)
 				if !rightPiece.IsEmpty() && rightPiece.GetPlayer() != p.Player {
-					moves = append(moves, Move{
-						Type:             'C',
-						Piece:            'P',
-						IsDisambiguation: false,
-						From:             p.Location,
-						To:               Location{p.Location.X + 1, p.Location.Y + 1},
-					})
+					// check if the pawn can be promoted
+					if p.Location.X == 6 {
+						moves = appendPromotions(moves, p.Location, Location{p.Location.X + 1, p.Location.Y + 1})
+					} else {
+						moves = append(moves, Move{
+							Type:             'C',
+							Piece:            'P',
+							IsDisambiguation: false,
+							From:             p.Location,
+							To:               Location{p.Location.X + 1, p.Location.Y + 1},
+						})
+					}
 				}
 			}
 		}
 
 		// En passant (dont you dare forget this)
-		if b.LastMove.Type == 'M' && b.LastMove.Piece == 'P' {
-			// left en passant
-			if p.Location.X == 5 && b.LastMove.To.Y == p.Location.Y-1 && b.LastMove.To.X == p.Location.X {
-				moves = append(moves, Move{
-					Type:             'E',
-					Piece:            'P',
-					IsDisambiguation: false,
-					From:             p.Location,
-					To:               Location{p.Location.X + 1, p.Location.Y - 1},
-				})
-			}
-			// right en passant
-			if p.Location.X == 5 && b.LastMove.To.Y == p.Location.Y+1 && b.LastMove.To.X == p.Location.X {
+		if target, ok := b.enPassantTarget(p.Player); ok && target.X == p.Location.X+1 {
+			if target.Y == p.Location.Y-1 || target.Y == p.Location.Y+1 {
 				moves = append(moves, Move{
 					Type:             'E',
 					Piece:            'P',
 					IsDisambiguation: false,
 					From:             p.Location,
-					To:               Location{p.Location.X + 1, p.Location.Y + 1},
+					To:               target,
 				})
 			}
 		}
@@ -172,15 +171,15 @@ func (p PlayerPiece) GetPawnMoves(b *Board) []Move {
 					From:             p.Location,
 					To:               Location{p.Location.X - 1, p.Location.Y},
 				})
-			}
-			if b.GetPiece(p.Location.X-2, p.Location.Y).IsEmpty() {
-				moves = append(moves, Move{
-					Type:             'M',
-					Piece:            'P',
-					IsDisambiguation: false,
-					From:             p.Location,
-					To:               Location{p.Location.X - 2, p.Location.Y},
-				})
+				if b.GetPiece(p.Location.X-2, p.Location.Y).IsEmpty() {
+					moves = append(moves, Move{
+						Type:             'M',
+						Piece:            'P',
+						IsDisambiguation: false,
+						From:             p.Location,
+						To:               Location{p.Location.X - 2, p.Location.Y},
+					})
+				}
 			}
 		}
 		// A pawn can move one space forward.
@@ -241,7 +240,7 @@ func (p PlayerPiece) GetPawnMoves(b *Board) []Move {
 				}
 			}
 			// right
-			if p.Location.X < 7 {
+			if p.Location.Y < 7 {
 				rightPiece := b.GetPiece(p.Location.X-1, p.Location.Y+1)
 				if !rightPiece.IsEmpty() && rightPiece.GetPlayer() != p.Player {
 					// check if the pawn can be promoted
@@ -271,25 +270,14 @@ func (p PlayerPiece) GetPawnMoves(b *Board) []Move {
 		}
 
 		// En passant (dont you dare forget this)
-		if b.LastMove.Type == 'M' && b.LastMove.Piece == 'P' {
-			// left en passant
-			if p.Location.X == 4 && b.LastMove.To.Y == p.Location.Y-1 && b.LastMove.To.X == p.Location.X {
-				moves = append(moves, Move{
-					Type:             'E',
-					Piece:            'P',
-					IsDisambiguation: false,
-					From:             p.Location,
-					To:               Location{p.Location.X - 1, p.Location.Y - 1},
-				})
-			}
-			// right en passant
-			if p.Location.X == 4 && b.LastMove.To.Y == p.Location.Y+1 && b.LastMove.To.X == p.Location.X {
+		if target, ok := b.enPassantTarget(p.Player); ok && target.X == p.Location.X-1 {
+			if target.Y == p.Location.Y-1 || target.Y == p.Location.Y+1 {
 				moves = append(moves, Move{
 					Type:             'E',
 					Piece:            'P',
 					IsDisambiguation: false,
 					From:             p.Location,
-					To:               Location{p.Location.X - 1, p.Location.Y + 1},
+					To:               target,
 				})
 			}
 		}
@@ -460,38 +448,7 @@ func (p PlayerPiece) GetKingMoves(b *Board) []Move {
 		}
 	}
 	//Castling
-	if b.CheckPlayerInCheck(p.Player) {
-		return moves
-	}
-	if p.Player == 1 {
-		if b.WhiteKingSideCastle {
-			// Check if there are pieces between the king and the rook
-			if b.GetPiece(0, 5).IsEmpty() && b.GetPiece(0, 6).IsEmpty() {
-				moves = append(moves, Move{'K', 'K', true, p.Location, Location{0, 6}})
-			}
-		}
-		if b.WhiteQueenSideCastle {
-			// Check if there are pieces between the king and the rook
-			if b.GetPiece(0, 3).IsEmpty() && b.GetPiece(0, 2).IsEmpty() && b.GetPiece(0, 1).IsEmpty() {
-				moves = append(moves, Move{'K', 'K', true, p.Location, Location{0, 2}})
-			}
-		}
-	} else {
-		if b.BlackKingSideCastle {
-			// Check if there are pieces between the king and the rook
-			if b.GetPiece(7, 5).IsEmpty() && b.GetPiece(7, 6).IsEmpty() {
-				moves = append(moves, Move{'K', 'K', true, p.Location, Location{7, 6}})
-			}
-		}
-		if b.BlackQueenSideCastle {
-			// Check if there are pieces between the king and the rook
-			if b.GetPiece(7, 3).IsEmpty() && b.GetPiece(7, 2).IsEmpty() && b.GetPiece(7, 1).IsEmpty() {
-				moves = append(moves, Move{'K', 'K', true, p.Location, Location{7, 2}})
-			}
-		}
-	}
-
-	return moves
+	return b.appendCastlingMoves(moves, p.Player)
 }
 
 func (p PlayerPiece) GetChar() rune {
@@ -588,27 +545,52 @@ type Piece interface {
 }
 
 type Move struct {
-	Type             rune // 'M' - move, 'C' - capture, 'E' - en passant, 'P' - pawn promotion, 'K' - castle, 'I' - initial position
-	Piece            rune // 'P' - pawn, 'R' - rook, 'N' - knight, 'B' - bishop, 'Q' - queen, 'K' - king
+	Type             rune // 'M' - move, 'C' - capture, 'E' - en passant, 'P' - pawn promotion, 'K' - castle, 'I' - initial position, 'D' - draw claim, 'U' - take back, 'R' - resign, 'O' - offer draw, 'A' - accept draw, 'N' - decline draw, 'X' - abandon
+	Piece            rune // 'P' - pawn, 'R' - rook, 'N' - knight, 'B' - bishop, 'Q' - queen, 'K' - king, for 'U', 'R', 'O', 'A' and 'N' 'W' or 'B' - the player taking the action
 	IsDisambiguation bool // If true, the move is disambiguated by the FromX and FromY fields.
 	From             Location
 	To               Location
 }
 
 // Detect if a move (from, to) is valid.
+// A pawn reaching the last rank promotes to a queen, use ValidPromotionMove to pick another piece.
 // Return (isValid, move)
 func ValidMove(from Location, to Location, player int, b *Board) (bool, Move) {
+	return ValidPromotionMove(from, to, ' ', player, b)
+}
+
+// Detect if a move (from, to) promoting to the given piece ('Q', 'R', 'B' or 'N') is valid.
+// The promotion piece must be ' ' for moves that are not promotions, for promotions ' ' means a queen.
+// Return (isValid, move)
+func ValidPromotionMove(from Location, to Location, promotion rune, player int, b *Board) (bool, Move) {
+	if promotion >= 'a' && promotion <= 'z' {
+		promotion -= 32
+	}
 	allValidMoves := b.GetPlayerLegalMoves(player)
 	for _, move := range allValidMoves {
+		if move.Type == 'P' && move.From == from && move.To == to {
+			if move.Piece == promotion || (promotion == ' ' && move.Piece == 'Q') {
+				return true, move
+			}
+			continue
+		}
+		if promotion != ' ' {
+			continue
+		}
 		if move.From == from && move.To == to {
 			return true, move
 		}
+		// Castling can also be given as the king taking its own rook,
+		// which is the only way to tell the moves apart in some Chess960 positions.
+		if move.Type == 'K' && move.From == from && b.castlingRookLocation(move) == to {
+			return true, move
+		}
 	}
 	return false, Move{' ', ' ', false, Location{0, 0}, Location{0, 0}}
 }
 
 // Deserialize a move from a string.
-// Example move: "d2d4"
+// Example move: "d2d4", or "e7e8n" for a promotion to a knight
 func DeserializeMove(s string, color int, b *Board) (bool, Move) {
 	fmt.Println("Deserializing move: " + s)
 	move := Move{' ', ' ', false, Location{0, 0}, Location{0, 0}}
@@ -621,10 +603,20 @@ func DeserializeMove(s string, color int, b *Board) (bool, Move) {
 	var moveFrom Location
 	var moveTo Location
 	isValid, moveFrom = GridToLocation(s[0:2])
+	if !isValid {
+		return false, move
+	}
 	isValid, moveTo = GridToLocation(s[2:4])
 	if !isValid {
 		return false, move
 	}
+	// Promotion piece
+	promotion := ' '
+	if len(s) == 5 {
+		promotion = rune(s[4])
+	} else if len(s) > 5 {
+		return false, move
+	}
 	//fmt.Println("Check piece")
 	// Check piece
 	piece := b.GetPieceAtLocation(moveFrom)
@@ -633,7 +625,7 @@ func DeserializeMove(s string, color int, b *Board) (bool, Move) {
 	}
 	//fmt.Println("Check move")
 	// Check if move is valid
-	isValid, move = ValidMove(moveFrom, moveTo, color, b)
+	isValid, move = ValidPromotionMove(moveFrom, moveTo, promotion, color, b)
 	if !isValid {
 		return false, move
 	}
@@ -642,6 +634,9 @@ func DeserializeMove(s string, color int, b *Board) (bool, Move) {
 }
 
 // Translate the move to algebraic notation
+// Without the board the notation cannot tell check, mate or the minimal disambiguation,
+// a disambiguated move (see DisambiguateMoves) gets its whole from square.
+// Use Board.MoveToSAN for Standard Algebraic Notation.
 func (m Move) ToString() string {
 	// Panic if the move is invalid.
 	if m.Type == ' ' {
@@ -652,7 +647,11 @@ func (m Move) ToString() string {
 		panic("Move out of bounds")
 	}
 	// Translate the move to a string.
+	// Pawn moves have no piece letter.
 	moveString := string(m.Piece)
+	if m.Piece == 'P' || m.Type == 'P' || m.Type == 'E' {
+		moveString = ""
+	}
 	switch m.Type {
 	case 'M':
 		if m.IsDisambiguation {
@@ -661,31 +660,43 @@ func (m Move) ToString() string {
 			moveString = moveString + LocationToGrid(m.To)
 		}
 	case 'C':
-		if m.IsDisambiguation {
+		if m.Piece == 'P' {
+			moveString = string(rune('a'+m.From.Y)) + "x" + LocationToGrid(m.To)
+		} else if m.IsDisambiguation {
 			moveString = moveString + LocationToGrid(m.From) + "x" + LocationToGrid(m.To)
 		} else {
 			moveString = moveString + "x" + LocationToGrid(m.To)
 		}
 	case 'E':
-		moveString = moveString + "x" + LocationToGrid(m.To)
+		moveString = string(rune('a'+m.From.Y)) + "x" + LocationToGrid(m.To)
 	case 'P':
+		// The piece of a promotion is the piece the pawn promotes to.
+		if m.From.Y != m.To.Y {
+			moveString = string(rune('a'+m.From.Y)) + "x"
+		}
 		moveString = moveString + LocationToGrid(m.To) + "=" + string(m.Piece)
 	case 'K':
-		if m.To.X == 6 {
+		if m.To.Y == 6 {
 			moveString = "O-O"
 		} else {
 			moveString = "O-O-O"
 		}
 	}
-	// if m.IsCheck() {
-	// 	moveString = moveString + "+"
-	// }
 	return moveString
 }
 
+// Translate the move to UCI notation, e.g. "e2e4" or "e7e8q".
+func (m Move) UCI() string {
+	uci := LocationToGrid(m.From) + LocationToGrid(m.To)
+	if m.Type == 'P' {
+		uci += string(m.Piece + 32)
+	}
+	return uci
+}
+
 // x,y to grid location
 func LocationToGrid(l Location) string {
-	return string('a'+l.Y) + string('1'+l.X)
+	return string(rune('a'+l.Y)) + string(rune('1'+l.X))
 }
 
 // grid location to x,y
@@ -701,16 +712,13 @@ func GridToLocation(grid string) (bool, Location) {
 }
 
 // Check if a given move is check.
-// TODO:
+// The move must be legal on the board, it is made and taken back again.
 func (m Move) IsCheck(b *Board) bool {
-	// // Make a copy of the board.
-	// newBoard := b.Copy()
-	// // Make the move.
-	// newBoard.MakeMove(m)
-	// // Check if the king is in check.
-	// return newBoard.IsInCheck(b.Turn)
-
-	return false
+	player := b.GetPieceAtLocation(m.From).GetPlayer()
+	undo := b.MakeMove(m)
+	isCheck := b.CheckPlayerInCheck(3 - player)
+	b.UnmakeMove(undo)
+	return isCheck
 }
 
 // Shuffle a slice of moves
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
+	"strings"
+	"sync"
 )
 
 // player is the struct that represents a player in the game.
@@ -15,18 +20,61 @@ import (
 // - computer player (and more variations of alg)
 
 type PlayerController interface {
-	GetMove(*Board) Move
-	SendMove(move Move)
+	// Get the move of the player on a copy of the game's board.
+	// The move can also be an action such as ResignMove.
+	// The context is done when the player's time is up or the game does not need the move anymore,
+	// the player should then return as soon as it can, e.g. with the context's error.
+	// Any other error ends the game, the player loses by abandonment.
+	GetMove(ctx context.Context, b *Board) (Move, error)
 }
 
+// Returned by the computer players when they are asked for a move in a position without any.
+var ErrNoLegalMoves = errors.New("no legal moves")
+
 type HumanPlayer struct {
 	Color int // 1 - white, 2 - black
 }
 
+// Lines typed on the console.
+// A single goroutine reads the console for all the human players, see readConsoleLine.
+var (
+	consoleLines    chan string
+	consoleReadOnce sync.Once
+)
+
+// Wait for a line typed on the console.
+// Returns the error of the context if it is done first, the line is then read by the next call.
+// Returns io.EOF when the console is closed.
+func readConsoleLine(ctx context.Context) (string, error) {
+	consoleReadOnce.Do(func() {
+		consoleLines = make(chan string)
+		go func() {
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				text, err := reader.ReadString('\n')
+				if err != nil {
+					close(consoleLines)
+					return
+				}
+				consoleLines <- text
+			}
+		}()
+	})
+	select {
+	case text, ok := <-consoleLines:
+		if !ok {
+			return "", io.EOF
+		}
+		return text, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
 // Prompts the user to move a piece.
-func (p *HumanPlayer) GetMove(b *Board) Move {
+// Returns the error of the context if it is done while waiting for the user.
+func (p *HumanPlayer) GetMove(ctx context.Context, b *Board) (Move, error) {
 
-	reader := bufio.NewReader(os.Stdin)
 	var move Move
 	var from Location
 	var to Location
@@ -35,8 +83,27 @@ func (p *HumanPlayer) GetMove(b *Board) Move {
 	// Prompt user for which piece to move
 	// If the piece is not owned by the player, prompt again
 	for {
-		fmt.Print("Which piece do you want to move? (e.g. a1): ")
-		text, _ := reader.ReadString('\n')
+		fmt.Print("Which piece do you want to move? (e.g. a1, \"draw\" to claim a draw, \"undo\" to take back your last move, \"resign\", \"offer draw\", \"accept draw\" or \"decline draw\"): ")
+		text, err := readConsoleLine(ctx)
+		if err != nil {
+			return Move{}, err
+		}
+		switch strings.TrimSpace(text) {
+		// Claim a draw by repetition or the 50 move rule instead of moving
+		case "draw":
+			return ClaimDrawMove(p.Color), nil
+		// Take back the last move
+		case "undo":
+			return TakeBackMove(p.Color), nil
+		case "resign":
+			return ResignMove(p.Color), nil
+		case "offer draw":
+			return OfferDrawMove(p.Color), nil
+		case "accept draw":
+			return AcceptDrawMove(p.Color), nil
+		case "decline draw":
+			return DeclineDrawMove(p.Color), nil
+		}
 		if len(text) < 2 {
 			fmt.Print("Invalid piece. ")
 			continue
@@ -63,7 +130,10 @@ func (p *HumanPlayer) GetMove(b *Board) Move {
 	// If the move is not legal, prompt again
 	for {
 		fmt.Print("Where do you want to move the piece? (e.g. a1): ")
-		text, _ := reader.ReadString('\n')
+		text, err := readConsoleLine(ctx)
+		if err != nil {
+			return Move{}, err
+		}
 		if len(text) < 2 {
 			fmt.Print("Invalid piece. ")
 			continue
@@ -87,7 +157,24 @@ func (p *HumanPlayer) GetMove(b *Board) Move {
 		fmt.Print("Invalid move. ")
 	}
 
-	return move
+	// Prompt user for the piece to promote to
+	for move.Type == 'P' {
+		fmt.Print("Which piece do you want to promote to? (q, r, b or n): ")
+		text, err := readConsoleLine(ctx)
+		if err != nil {
+			return Move{}, err
+		}
+		text = strings.TrimSpace(text)
+		if len(text) == 1 {
+			if isValid, promotion := ValidPromotionMove(from, to, rune(text[0]), p.Color, b); isValid {
+				move = promotion
+				break
+			}
+		}
+		fmt.Print("Invalid piece. ")
+	}
+
+	return move, nil
 }
 
 type RandomComputerPlayer struct {
@@ -95,11 +182,14 @@ type RandomComputerPlayer struct {
 }
 
 // This player will randomly pick a legal move from the list
-func (p *RandomComputerPlayer) GetMove(b *Board) Move {
+func (p *RandomComputerPlayer) GetMove(ctx context.Context, b *Board) (Move, error) {
 	moves := b.GetPlayerLegalMoves(p.Color)
+	if len(moves) == 0 {
+		return Move{}, ErrNoLegalMoves
+	}
 	// Choose a random move from the list
 	move := moves[rand.Intn(len(moves))]
-	return move
+	return move, nil
 }
 
 type MinimaxComputerPlayer struct {
@@ -108,15 +198,22 @@ type MinimaxComputerPlayer struct {
 }
 
 // This player will use the minimax algorithm to find the best move
-func (p *MinimaxComputerPlayer) GetMove(b *Board) Move {
+// The search stops when the context is done.
+func (p *MinimaxComputerPlayer) GetMove(ctx context.Context, b *Board) (Move, error) {
+	if len(b.GetPlayerLegalMoves(p.Color)) == 0 {
+		return Move{}, ErrNoLegalMoves
+	}
 	var maximizingPlayer bool
 	if p.Color == 1 {
 		maximizingPlayer = true
 	} else {
 		maximizingPlayer = false
 	}
-	_, move := AlphaBetaAlg(b, p.Depth, maximizingPlayer, -100000, 100000)
-	return move
+	_, move := AlphaBetaAlg(ctx, b, p.Depth, maximizingPlayer, -100000, 100000)
+	if err := ctx.Err(); err != nil {
+		return Move{}, err
+	}
+	return move, nil
 }
 
 func Max(a, b int) int {
@@ -142,18 +239,23 @@ func MinMaxHeuristic(b *Board, playerColor int) int {
 	if b.CheckPlayerInCheckmate(2) {
 		return 10000
 	}
+	// nobody can win a dead position
+	if b.IsInsufficientMaterial() {
+		return 0
+	}
 	return b.GetPieceValue(1) - b.GetPieceValue(2)
 }
 
 // MinMax algorithm (No alpha-beta pruning, so pretty slow)
 // Input parameters:
+// - ctx: the search stops when it is done, the result is then meaningless
 // - b: the board
 // - depth: the depth of the search tree
 // - maximizingPlayer: true if the current player is the maximizing player
 // - playerColor: the color of the current player
 // Output:
 // - the best move & heuristic value
-func MinMaxAlg(b *Board, depth int, maximizingPlayer bool) (int, Move) {
+func MinMaxAlg(ctx context.Context, b *Board, depth int, maximizingPlayer bool) (int, Move) {
 	var playerColor int
 	if maximizingPlayer {
 		playerColor = 1
@@ -161,6 +263,9 @@ func MinMaxAlg(b *Board, depth int, maximizingPlayer bool) (int, Move) {
 		playerColor = 2
 	}
 
+	if ctx.Err() != nil {
+		return 0, Move{}
+	}
 	if depth == 0 || b.IsTerminal() {
 		return MinMaxHeuristic(b, playerColor), Move{}
 	}
@@ -171,9 +276,9 @@ func MinMaxAlg(b *Board, depth int, maximizingPlayer bool) (int, Move) {
 		moves := b.GetPlayerLegalMoves(playerColor)
 		moves = ShuffleMoves(moves) // To make it more fun
 		for _, move := range moves {
-			newBoard := b.Copy()
-			newBoard.MakeMove(move)
-			newValue, _ := MinMaxAlg(&newBoard, depth-1, false)
+			undo := b.MakeMove(move)
+			newValue, _ := MinMaxAlg(ctx, b, depth-1, false)
+			b.UnmakeMove(undo)
 			value = Max(value, newValue)
 			if value == newValue {
 				bestMove = move
@@ -185,9 +290,9 @@ func MinMaxAlg(b *Board, depth int, maximizingPlayer bool) (int, Move) {
 		moves := b.GetPlayerLegalMoves(playerColor)
 		moves = ShuffleMoves(moves) // To make it more fun
 		for _, move := range moves {
-			newBoard := b.Copy()
-			newBoard.MakeMove(move)
-			newValue, _ := MinMaxAlg(&newBoard, depth-1, true)
+			undo := b.MakeMove(move)
+			newValue, _ := MinMaxAlg(ctx, b, depth-1, true)
+			b.UnmakeMove(undo)
 			value = Min(value, newValue)
 			if value == newValue {
 				bestMove = move
@@ -198,7 +303,7 @@ func MinMaxAlg(b *Board, depth int, maximizingPlayer bool) (int, Move) {
 }
 
 // Same as MinMax Alg but with alpha-beta pruning
-func AlphaBetaAlg(b *Board, depth int, maximizingPlayer bool, alpha int, beta int) (int, Move) {
+func AlphaBetaAlg(ctx context.Context, b *Board, depth int, maximizingPlayer bool, alpha int, beta int) (int, Move) {
 	var playerColor int
 	if maximizingPlayer {
 		playerColor = 1
@@ -206,6 +311,9 @@ func AlphaBetaAlg(b *Board, depth int, maximizingPlayer bool, alpha int, beta in
 		playerColor = 2
 	}
 
+	if ctx.Err() != nil {
+		return 0, Move{}
+	}
 	if depth == 0 || b.IsTerminal() {
 		return MinMaxHeuristic(b, playerColor), Move{}
 	}
@@ -216,9 +324,9 @@ func AlphaBetaAlg(b *Board, depth int, maximizingPlayer bool, alpha int, beta in
 		moves := b.GetPlayerLegalMoves(playerColor)
 		moves = ShuffleMoves(moves) // To make it more fun
 		for _, move := range moves {
-			newBoard := b.Copy()
-			newBoard.MakeMove(move)
-			newValue, _ := AlphaBetaAlg(&newBoard, depth-1, false, alpha, beta)
+			undo := b.MakeMove(move)
+			newValue, _ := AlphaBetaAlg(ctx, b, depth-1, false, alpha, beta)
+			b.UnmakeMove(undo)
 			if newValue > beta {
 				return newValue, move
 			}
@@ -234,9 +342,9 @@ func AlphaBetaAlg(b *Board, depth int, maximizingPlayer bool, alpha int, beta in
 		moves := b.GetPlayerLegalMoves(playerColor)
 		moves = ShuffleMoves(moves) // To make it more fun
 		for _, move := range moves {
-			newBoard := b.Copy()
-			newBoard.MakeMove(move)
-			newValue, _ := AlphaBetaAlg(&newBoard, depth-1, true, alpha, beta)
+			undo := b.MakeMove(move)
+			newValue, _ := AlphaBetaAlg(ctx, b, depth-1, true, alpha, beta)
+			b.UnmakeMove(undo)
 			if newValue < alpha {
 				return newValue, move
 			}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 )
 
 // External players and API stuff
 
+// Returned by RemotePlayer.GetMove when the client left the game.
+var ErrPlayerLeft = errors.New("the player left the game")
+
 type RemotePlayer struct {
 	Color int
 
 	playerMove chan Move
 
+	// Closed when the client leaves, see Leave.
+	left      chan struct{}
+	leaveOnce sync.Once
+
 	board *Board
 }
 
@@ -18,20 +28,44 @@ type RemotePlayer struct {
 func (p *RemotePlayer) Init(color int, b *Board) {
 	p.Color = color
 	p.playerMove = make(chan Move, 1)
+	p.left = make(chan struct{})
 	p.board = b
 }
 
-// TODO: implement this
-func (p *RemotePlayer) GetMove(b *Board) Move {
+// Wait for the client to send a move.
+// Returns ErrPlayerLeft if the client leaves, and the error of the context when it is done,
+// so the game never waits for a client that is gone.
+func (p *RemotePlayer) GetMove(ctx context.Context, b *Board) (Move, error) {
 	// ==== Get the move ==== //
 	fmt.Println("Waiting for move from client")
 	// Wait for the client to send the move
-	move := <-p.playerMove
-	fmt.Println("Move received from client")
+	select {
+	case move := <-p.playerMove:
+		fmt.Println("Move received from client")
+		return move, nil
+	case <-p.left:
+		return Move{}, ErrPlayerLeft
+	case <-ctx.Done():
+		return Move{}, ctx.Err()
+	}
+}
 
-	return move
+// Pass a move of the client to the game.
+// A move sent while the previous one was not taken yet is dropped,
+// so the server never blocks on a game that stopped asking for moves.
+// Returns false if the move is dropped.
+func (p *RemotePlayer) SendMove(move Move) bool {
+	select {
+	case p.playerMove <- move:
+		return true
+	default:
+		return false
+	}
 }
 
-func (p *RemotePlayer) SendMove(move Move) {
-	p.playerMove <- move
+// Tell the game the client left, any wait for its move ends with ErrPlayerLeft.
+func (p *RemotePlayer) Leave() {
+	p.leaveOnce.Do(func() {
+		close(p.left)
+	})
 }
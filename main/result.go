@@ -0,0 +1,166 @@
+package main
+
+// The result of a game: who won and why the game ended.
+
+// Who won the game.
+type Outcome int
+
+const (
+	// The game is not over yet
+	Ongoing Outcome = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+// Why the game ended.
+type Termination int
+
+const (
+	// The game is not over yet
+	NoTermination Termination = iota
+	Checkmate
+	Stalemate
+	Resignation
+	// A player ran out of time, a draw if the opponent can not checkmate
+	Timeout
+	// Draw offered by one player and accepted by the other
+	Agreement
+	// Threefold repetition claimed by a player
+	ThreefoldRepetition
+	FivefoldRepetition
+	// 50 moves without a capture or a pawn move, claimed by a player
+	FiftyMoveRule
+	// 75 moves without a capture or a pawn move, the game ends without a claim
+	SeventyFiveMoveRule
+	InsufficientMaterial
+	// A player left the game, or could not go on, see PlayerErrorEvent
+	Abandonment
+)
+
+type GameResult struct {
+	Outcome     Outcome     `json:"outcome"`
+	Termination Termination `json:"termination"`
+}
+
+// The result of a game won by the given player.
+func Win(player int, termination Termination) GameResult {
+	if player == 2 {
+		return GameResult{BlackWins, termination}
+	}
+	return GameResult{WhiteWins, termination}
+}
+
+// The result of a drawn game.
+func DrawBy(termination Termination) GameResult {
+	return GameResult{Draw, termination}
+}
+
+// Check if the game is over.
+func (r GameResult) IsOver() bool {
+	return r.Outcome != Ongoing
+}
+
+// Get the player who won, 0 for a draw or a game that is not over.
+func (r GameResult) Winner() int {
+	switch r.Outcome {
+	case WhiteWins:
+		return 1
+	case BlackWins:
+		return 2
+	}
+	return 0
+}
+
+// Get the PGN result token: "1-0", "0-1", "1/2-1/2" or "*" while the game is not over.
+func (r GameResult) Token() string {
+	switch r.Outcome {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// Get the value of the PGN Termination tag.
+func (r GameResult) PGNTermination() string {
+	switch r.Termination {
+	case NoTermination:
+		return "unterminated"
+	case Timeout:
+		return "time forfeit"
+	case Abandonment:
+		return "abandoned"
+	}
+	return "normal"
+}
+
+// Describe the result, e.g. "Black player won by checkmate".
+func (r GameResult) String() string {
+	if r.Outcome == Ongoing {
+		return "Game in progress"
+	}
+	if r.Outcome == Draw {
+		switch r.Termination {
+		case Timeout:
+			return "Draw by timeout with insufficient material"
+		case FiftyMoveRule:
+			return "Draw by the 50 move rule"
+		case SeventyFiveMoveRule:
+			return "Draw by the 75 move rule"
+		}
+		return "Draw by " + r.Termination.description()
+	}
+	winner := playerName(r.Winner())
+	switch r.Termination {
+	case Timeout:
+		return winner + " won on time"
+	case Abandonment:
+		return winner + " won, the opponent left the game"
+	}
+	return winner + " won by " + r.Termination.description()
+}
+
+var outcomeNames = []string{"ongoing", "whiteWins", "blackWins", "draw"}
+
+var terminationNames = []string{
+	"", "checkmate", "stalemate", "resignation", "timeout", "agreement", "threefoldRepetition",
+	"fivefoldRepetition", "fiftyMoveRule", "seventyFiveMoveRule", "insufficientMaterial", "abandonment",
+}
+
+// Outcomes are written as "whiteWins", "blackWins", "draw" or "ongoing", e.g. in JSON.
+func (o Outcome) String() string {
+	return outcomeNames[o]
+}
+
+func (o Outcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// Terminations are written in camel case, e.g. "threefoldRepetition",
+// and as an empty string for a game that is not over.
+func (t Termination) String() string {
+	return terminationNames[t]
+}
+
+func (t Termination) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Describe the termination, e.g. "threefold repetition".
+func (t Termination) description() string {
+	switch t {
+	case Agreement:
+		return "agreement"
+	case ThreefoldRepetition:
+		return "threefold repetition"
+	case FivefoldRepetition:
+		return "fivefold repetition"
+	case InsufficientMaterial:
+		return "insufficient material"
+	}
+	return t.String()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Standard Algebraic Notation (SAN), e.g. "Nbd7", "exd6", "e8=Q+" or "O-O-O#".
+// Unlike Move.ToString the SAN of a move depends on the position,
+// which decides the disambiguation and the check and mate markers.
+
+// Get the SAN of a legal move of the given board.
+func (b *Board) MoveToSAN(m Move) string {
+	san := b.sanWithoutSuffix(m)
+	player := b.GetPieceAtLocation(m.From).GetPlayer()
+	undo := b.MakeMove(m)
+	if b.CheckPlayerInCheck(3 - player) {
+		if len(b.GetPlayerLegalMoves(3-player)) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	b.UnmakeMove(undo)
+	return san
+}
+
+// Get the SAN of a legal move without the check and mate markers.
+func (b *Board) sanWithoutSuffix(m Move) string {
+	switch {
+	case m.Type == 'K':
+		if m.To.Y == 6 {
+			return "O-O"
+		}
+		return "O-O-O"
+
+	case m.Type == 'E' || m.Type == 'P' || m.Piece == 'P':
+		// Pawn moves have no piece letter, captures start with the file of the pawn.
+		san := ""
+		if m.From.Y != m.To.Y {
+			san = string(rune('a'+m.From.Y)) + "x"
+		}
+		san += LocationToGrid(m.To)
+		if m.Type == 'P' {
+			san += "=" + string(m.Piece)
+		}
+		return san
+	}
+
+	san := string(m.Piece) + b.disambiguation(m)
+	if m.Type == 'C' {
+		san += "x"
+	}
+	return san + LocationToGrid(m.To)
+}
+
+// Get the part of the from square needed to tell a move apart from the moves
+// of the other pieces of the same type to the same square:
+// the file if it is enough, otherwise the rank, otherwise the whole square.
+func (b *Board) disambiguation(m Move) string {
+	player := b.GetPieceAtLocation(m.From).GetPlayer()
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range b.GetPlayerLegalMoves(player) {
+		if !isPieceMove(other) || other.Piece != m.Piece || other.To != m.To || other.From == m.From {
+			continue
+		}
+		ambiguous = true
+		sameFile = sameFile || other.From.Y == m.From.Y
+		sameRank = sameRank || other.From.X == m.From.X
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(rune('a' + m.From.Y))
+	case !sameRank:
+		return string(rune('1' + m.From.X))
+	}
+	return LocationToGrid(m.From)
+}
+
+// Check if a move is a plain move or capture of a piece other than a pawn,
+// the only moves that can need disambiguation.
+// Promotions are not: their Piece is the piece the pawn promotes to.
+func isPieceMove(m Move) bool {
+	return (m.Type == 'M' || m.Type == 'C') && m.Piece != 'P'
+}
+
+// Find the legal move of the side to move written in SAN.
+// Also accepts "0-0" for castling, a missing "x" on captures,
+// a promotion without "=" and trailing "+", "#", "!" and "?" marks.
+func (b *Board) ParseSAN(san string) (Move, error) {
+	text := strings.TrimSpace(san)
+	text = strings.TrimRight(text, "+#!?")
+	if text == "" {
+		return Move{}, fmt.Errorf("invalid SAN %q: empty move", san)
+	}
+	moves := b.GetPlayerLegalMoves(b.State)
+
+	// Castling
+	castling := strings.ReplaceAll(text, "0", "O")
+	if castling == "O-O" || castling == "O-O-O" {
+		file := 6
+		if castling == "O-O-O" {
+			file = 2
+		}
+		for _, move := range moves {
+			if move.Type == 'K' && move.To.Y == file {
+				return move, nil
+			}
+		}
+		return Move{}, fmt.Errorf("illegal move %q: castling is not possible", san)
+	}
+
+	// Piece letter, pawn moves have none
+	piece := 'P'
+	if strings.ContainsRune("NBRQK", rune(text[0])) {
+		piece = rune(text[0])
+		text = text[1:]
+	}
+
+	// Promotion piece, with or without "="
+	promotion := ' '
+	if n := len(text); n > 0 && strings.ContainsRune("NBRQ", rune(text[n-1])) {
+		promotion = rune(text[n-1])
+		text = strings.TrimSuffix(text[:n-1], "=")
+		if piece != 'P' {
+			return Move{}, fmt.Errorf("invalid SAN %q: only pawns can promote", san)
+		}
+	}
+
+	if strings.Contains(text, "=") {
+		return Move{}, fmt.Errorf("invalid SAN %q: bad promotion piece", san)
+	}
+
+	// Destination square
+	if len(text) < 2 {
+		return Move{}, fmt.Errorf("invalid SAN %q: missing destination square", san)
+	}
+	isValid, to := GridToLocation(text[len(text)-2:])
+	if !isValid {
+		return Move{}, fmt.Errorf("invalid SAN %q: bad destination square", san)
+	}
+
+	// What is left can be a capture marker and the file and rank of the piece
+	fromFile, fromRank := -1, -1
+	for _, char := range strings.ReplaceAll(text[:len(text)-2], "x", "") {
+		switch {
+		case char >= 'a' && char <= 'h' && fromFile < 0:
+			fromFile = int(char - 'a')
+		case char >= '1' && char <= '8' && fromRank < 0:
+			fromRank = int(char - '1')
+		default:
+			return Move{}, fmt.Errorf("invalid SAN %q: unexpected %q", san, char)
+		}
+	}
+
+	var matches []Move
+	for _, move := range moves {
+		isPawnMove := move.Type == 'P' || move.Type == 'E' || move.Piece == 'P'
+		if move.To != to || move.Type == 'K' || isPawnMove != (piece == 'P') {
+			continue
+		}
+		if piece != 'P' && move.Piece != piece {
+			continue
+		}
+		if (fromFile >= 0 && move.From.Y != fromFile) || (fromRank >= 0 && move.From.X != fromRank) {
+			continue
+		}
+		if move.Type == 'P' {
+			if promotion == ' ' {
+				return Move{}, fmt.Errorf("invalid SAN %q: missing promotion piece", san)
+			}
+			if move.Piece != promotion {
+				continue
+			}
+		} else if promotion != ' ' {
+			continue
+		}
+		matches = append(matches, move)
+	}
+
+	switch len(matches) {
+	case 0:
+		return Move{}, fmt.Errorf("illegal move %q", san)
+	case 1:
+		return matches[0], nil
+	}
+	return Move{}, fmt.Errorf("ambiguous move %q: %d pieces can move there", san, len(matches))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Find the legal move of the side to move given as "e2e4", or "e7e8n" for a promotion.
+// Castling is given as the king moving to its target square.
+func testMove(t *testing.T, b *Board, coordinates string) Move {
+	t.Helper()
+	isValidFrom, from := GridToLocation(coordinates[0:2])
+	isValidTo, to := GridToLocation(coordinates[2:4])
+	promotion := ' '
+	if len(coordinates) == 5 {
+		promotion = rune(coordinates[4])
+	}
+	if !isValidFrom || !isValidTo {
+		t.Fatalf("bad coordinates %q", coordinates)
+	}
+	isValid, move := ValidPromotionMove(from, to, promotion, b.State, b)
+	if !isValid {
+		t.Fatalf("%s is not a legal move in %s", coordinates, b.ToFEN())
+	}
+	return move
+}
+
+func TestMoveToSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		san  string
+	}{
+		{"pawn push", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e4"},
+		{"knight", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", "Nf3"},
+		{"pawn capture", "4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4d5", "exd5"},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+		{"file disambiguation", "4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "a1d1", "Rad1"},
+		{"rank disambiguation", "4k3/8/8/R7/8/8/4K3/R7 w - - 0 1", "a1a3", "R1a3"},
+		{"square disambiguation", "K7/8/8/8/4Q2Q/k7/8/7Q w - - 0 1", "h4e1", "Qh4e1"},
+		{"pinned rival", "4k3/8/8/8/1b6/2N5/8/4K1N1 w - - 0 1", "g1e2", "Ne2"},
+		{"promotion is not a rival", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "h5e8", "Qxe8"},
+		{"promotion", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d7d8n", "d8=N"},
+		{"promotion capture", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d7e8q", "dxe8=Q"},
+		{"no check", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a7", "Ra7"},
+		{"check marker", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8+"},
+		{"mate marker", "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", "Qh4#"},
+		{"white castles king side", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"white castles queen side", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", "O-O-O"},
+		{"black castles king side", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8", "O-O"},
+		{"black castles queen side", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			move := testMove(t, &b, test.move)
+			if san := b.MoveToSAN(move); san != test.san {
+				t.Errorf("MoveToSAN(%s) = %q, want %q", test.move, san, test.san)
+			}
+			if b.ToFEN() != test.fen {
+				t.Errorf("MoveToSAN changed the board to %s", b.ToFEN())
+			}
+		})
+	}
+}
+
+func TestDisambiguateMoves(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		text string
+	}{
+		{"rival", "4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "a1d1", "Ra1d1"},
+		{"no rival", "4k3/8/8/8/8/8/4K3/R7 w - - 0 1", "a1d1", "Rd1"},
+		{"promotion is not a rival", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "h5e8", "Qxe8"},
+		{"promotion", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d7e8q", "dxe8=Q"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := testMove(t, &b, test.move)
+			moves := b.GetPlayerLegalMoves(b.State)
+			DisambiguateMoves(moves)
+			for _, move := range moves {
+				if move.Type == want.Type && move.Piece == want.Piece && move.From == want.From && move.To == want.To {
+					if text := move.ToString(); text != test.text {
+						t.Errorf("ToString() = %q, want %q", text, test.text)
+					}
+					return
+				}
+			}
+			t.Fatalf("%s is not in the legal moves", test.move)
+		})
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		san  string
+		move string
+	}{
+		{"pawn push", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4", "e2e4"},
+		{"knight", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nf3", "g1f3"},
+		{"file disambiguation", "4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rad1", "a1d1"},
+		{"rank disambiguation", "4k3/8/8/R7/8/8/4K3/R7 w - - 0 1", "R1a3", "a1a3"},
+		{"square disambiguation", "K7/8/8/8/4Q2Q/k7/8/7Q w - - 0 1", "Qh4e1", "h4e1"},
+		{"pinned rival", "4k3/8/8/8/1b6/2N5/8/4K1N1 w - - 0 1", "Ne2", "g1e2"},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", "e5d6"},
+		{"promotion", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d8=N", "d7d8n"},
+		{"promotion without =", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "dxe8Q", "d7e8q"},
+		{"missing x", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "Qe8", "h5e8"},
+		{"check marker", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "Ra8+", "a1a8"},
+		{"annotation", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4!?", "e2e4"},
+		{"castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"castling with zeros", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "0-0-0", "e8c8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := testMove(t, &b, test.move)
+			move, err := b.ParseSAN(test.san)
+			if err != nil {
+				t.Fatalf("ParseSAN(%q): %v", test.san, err)
+			}
+			if move != want {
+				t.Errorf("ParseSAN(%q) = %s, want %s", test.san, move.ToString(), want.ToString())
+			}
+		})
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		san  string
+		err  string
+	}{
+		{"empty", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "", "empty move"},
+		{"bad square", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nz9", "bad destination square"},
+		{"missing square", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "N", "missing destination square"},
+		{"unexpected character", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Ngyf3", "unexpected"},
+		{"illegal", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e5", "illegal move"},
+		{"pinned piece", "4k3/8/8/8/1b6/2N5/8/4K3 w - - 0 1", "Ne2", "illegal move"},
+		{"ambiguous", "4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rd1", "ambiguous move"},
+		{"castling not possible", "r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1", "O-O", "castling is not possible"},
+		{"missing promotion piece", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d8", "missing promotion piece"},
+		{"piece promotion", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "Qd8=Q", "only pawns can promote"},
+		{"bad promotion piece", "4r3/3P4/8/7Q/8/8/8/K1k5 w - - 0 1", "d8=K", "bad promotion piece"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := ParseFEN(test.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = b.ParseSAN(test.san)
+			if err == nil {
+				t.Fatalf("ParseSAN(%q) did not fail", test.san)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseSAN(%q) = %q, want an error containing %q", test.san, err, test.err)
+			}
+		})
+	}
+}
+
+// Every legal move written by MoveToSAN is read back by ParseSAN.
+func TestSANRoundTrip(t *testing.T) {
+	for _, position := range perftSuite {
+		b, err := ParseFEN(position.FEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range b.GetPlayerLegalMoves(b.State) {
+			san := b.MoveToSAN(move)
+			parsed, err := b.ParseSAN(san)
+			if err != nil {
+				t.Errorf("%s: ParseSAN(%q): %v", position.Name, san, err)
+				continue
+			}
+			if parsed != move {
+				t.Errorf("%s: ParseSAN(%q) = %s, want %s", position.Name, san, parsed.ToString(), move.ToString())
+			}
+		}
+	}
+}
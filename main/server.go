@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/gorilla/websocket"
 )
@@ -35,9 +36,73 @@ type Room struct {
 	// 1 - waiting for opponent
 	// 2 - game in progress
 	// 3 - game over
-	game  *Game
-	white *Player
-	black *Player
+	game    *Game
+	white   *Player
+	black   *Player
+	options RoomOptions
+
+	// The controllers the game gets the moves of the players from, indexed by color-1.
+	controllers [2]*RemotePlayer
+
+	// Color of the player asking to take back their last move, 0 if nobody is asking.
+	// The request expires when a move is made.
+	// Guarded by the lock of the game, like the game itself, see Room.lock.
+	takebackRequest int
+}
+
+// Get the color of a player in the room, -1 if the player is not playing in it.
+func (r *Room) playerColor(player *Player) int {
+	if player == r.white {
+		return 1
+	}
+	if player == r.black {
+		return 2
+	}
+	return -1
+}
+
+// Lock the room's game, which Play changes from the goroutine of the game loop.
+// The handlers of the client messages hold it while they read the game or the room's takebackRequest.
+func (r *Room) lock() {
+	r.game.Lock()
+}
+
+func (r *Room) unlock() {
+	r.game.Unlock()
+}
+
+// Send an action such as a resignation of the player to the game, see Game.SendAction.
+// The player is told if the action is dropped.
+func (r *Room) sendAction(player *Player, action Move) bool {
+	if r.game.SendAction(action) {
+		return true
+	}
+	player.conn.WriteJSON(&ServerError{Error: "The game is busy, try again"})
+	return false
+}
+
+// Get the controller of the player of the given color.
+func (r *Room) controller(color int) *RemotePlayer {
+	return r.controllers[color-1]
+}
+
+// Get the player of the given color.
+func (r *Room) player(color int) *Player {
+	if color == 1 {
+		return r.white
+	}
+	return r.black
+}
+
+// Options picked by the player creating a room.
+// Sent as JSON in the data of the createRoom message, an empty string means a classical game.
+type RoomOptions struct {
+	Chess960 bool `json:"chess960"`
+	// Chess960 starting position between 0 and 959, random if not given
+	Chess960Position *int `json:"chess960Position,omitempty"`
+	// Time control in the format of the PGN TimeControl tag, e.g. "300+2", see ParseTimeControl.
+	// No clock if not given.
+	TimeControl string `json:"timeControl,omitempty"`
 }
 
 // Random string generator for room names
@@ -102,6 +167,27 @@ type ClientMessage struct {
 type ServerMessage struct {
 	Type string `json:"type"`
 	Data string `json:"data"`
+	// Standard FEN of the board, sent along with gameState and gameResult
+	FEN string `json:"fen,omitempty"`
+	// PGN of the whole game, sent along with gameResult
+	PGN string `json:"pgn,omitempty"`
+	// Why the game ended as text, sent along with gameResult
+	Reason string `json:"reason,omitempty"`
+	// Outcome and termination of the game, sent along with gameResult
+	Result *GameResult `json:"result,omitempty"`
+	// Side on move, "w" or "b", sent along with gameState and gameResult
+	Turn string `json:"turn,omitempty"`
+	// Time left of the players, sent along with gameState and gameResult in games with a clock
+	Clock *ClockStatus `json:"clock,omitempty"`
+}
+
+// Clock of a game as sent to the clients
+type ClockStatus struct {
+	// Time left in milliseconds
+	White int64 `json:"white"`
+	Black int64 `json:"black"`
+	// The time control, e.g. "300+2"
+	TimeControl string `json:"timeControl"`
 }
 
 // Server Error message
@@ -133,7 +219,34 @@ func (s *Server) respond(player *Player, message []byte) {
 
 	// create a room
 	case "createRoom":
-		roomName := s.createRoom(player)
+		options := RoomOptions{}
+		if clientMessage.Data != "" {
+			err := json.Unmarshal([]byte(clientMessage.Data), &options)
+			if err == nil && options.Chess960Position != nil {
+				_, err = Chess960BackRank(*options.Chess960Position)
+			}
+			if err == nil && options.TimeControl != "" {
+				_, err = ParseTimeControl(options.TimeControl)
+			}
+			if err != nil {
+				// send error message back to client
+				serverError := &ServerError{
+					Error: "Invalid room options: " + err.Error(),
+				}
+				serverErrorJSON, err := json.Marshal(serverError)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				err = player.conn.WriteMessage(websocket.TextMessage, serverErrorJSON)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+				return
+			}
+		}
+		roomName := s.createRoom(player, options)
 		fmt.Println("Created room: ", roomName)
 		// send room name back to client
 		serverMessage := &ServerMessage{
@@ -254,6 +367,8 @@ func (s *Server) respond(player *Player, message []byte) {
 		} else {
 			return
 		}
+		player.room.lock()
+		defer player.room.unlock()
 		if playerColor != player.room.game.Board.State {
 			return
 		}
@@ -287,6 +402,12 @@ func (s *Server) respond(player *Player, message []byte) {
 
 		validSquare := []string{}
 		for _, move := range moves {
+			// Castling is sent as the king moving onto its rook: in Chess960 the king's target
+			// can be its own square or the target of a plain king move.
+			if move.Type == 'K' {
+				validSquare = append(validSquare, LocationToGrid(player.room.game.Board.castlingRookLocation(move)))
+				continue
+			}
 			validSquare = append(validSquare, LocationToGrid(move.To))
 		}
 
@@ -327,13 +448,29 @@ func (s *Server) respond(player *Player, message []byte) {
 		} else {
 			return
 		}
+		player.room.lock()
+		defer player.room.unlock()
 		if playerColor != player.room.game.Board.State {
 			return
 		}
 
 		// check locations
-		fromLocation := strings.Split(clientMessage.Data, ",")[0]
-		toLocation := strings.Split(clientMessage.Data, ",")[1]
+		// data is "e2,e4", or "e7,e8,n" with the piece a pawn promotes to
+		moveData := strings.Split(clientMessage.Data, ",")
+		if len(moveData) < 2 || len(moveData) > 3 {
+			fmt.Println("Invalid move received from client")
+			return
+		}
+		fromLocation := moveData[0]
+		toLocation := moveData[1]
+		promotion := ' '
+		if len(moveData) == 3 {
+			if len(moveData[2]) != 1 {
+				fmt.Println("Invalid promotion piece received from client")
+				return
+			}
+			promotion = rune(moveData[2][0])
+		}
 		isValid, from := GridToLocation(fromLocation)
 		if isValid == false {
 			fmt.Println("Invalid location received from client")
@@ -347,26 +484,152 @@ func (s *Server) respond(player *Player, message []byte) {
 
 		// check if the move is legal
 		var move Move
-		isValid, move = ValidMove(from, to, playerColor, &player.room.game.Board)
+		isValid, move = ValidPromotionMove(from, to, promotion, playerColor, &player.room.game.Board)
 		if isValid == false {
 			fmt.Println("Invalid move received from client")
 			break
 		}
 
+		// a move ends any takeback request
+		player.room.takebackRequest = 0
+
 		// make the move
-		if playerColor == 1 {
-			player.room.game.WhitePlayer.SendMove(move)
-		} else if playerColor == 2 {
-			player.room.game.BlackPlayer.SendMove(move)
+		if !player.room.controller(playerColor).SendMove(move) {
+			player.conn.WriteJSON(&ServerError{Error: "The game is not waiting for a move, the move was dropped"})
+		}
+
+	// claim a draw by threefold repetition or the 50 move rule
+	case "claimDraw":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		// check if it is the player's turn
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		room.lock()
+		defer room.unlock()
+		if playerColor != room.game.Board.State {
+			return
+		}
+
+		// check if the draw can be claimed
+		if !room.game.CanClaimDraw() {
+			player.conn.WriteJSON(&ServerError{Error: "The position did not occur three times and the last 50 moves had a capture or a pawn move"})
+			return
 		}
 
+		// claim the draw
+		room.sendAction(player, ClaimDrawMove(playerColor))
+
+	// ask the opponent to take back the last move
+	case "requestTakeback":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		room.lock()
+		defer room.unlock()
+		if !room.game.CanTakeBack(playerColor) {
+			player.conn.WriteJSON(&ServerError{Error: "There is no move to take back"})
+			return
+		}
+		room.takebackRequest = playerColor
+		room.send(3-playerColor, &ServerMessage{Type: "takebackRequested"})
+
+	// answer the opponent's takeback request
+	case "acceptTakeback", "declineTakeback":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		room.lock()
+		defer room.unlock()
+		requester := room.takebackRequest
+		if requester == 0 || requester == playerColor {
+			player.conn.WriteJSON(&ServerError{Error: "There is no takeback request to answer"})
+			return
+		}
+		room.takebackRequest = 0
+		if clientMessage.Type == "declineTakeback" {
+			room.send(requester, &ServerMessage{Type: "takebackDeclined"})
+			return
+		}
+
+		if room.sendAction(player, TakeBackMove(requester)) {
+			room.send(requester, &ServerMessage{Type: "takebackAccepted"})
+		}
+
+	// resign the game
+	case "resign":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		room.sendAction(player, ResignMove(playerColor))
+
+	// offer the opponent a draw
+	case "offerDraw":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		// the opponent is told by the game listener, see Room.listen
+		room.sendAction(player, OfferDrawMove(playerColor))
+
+	// answer the opponent's draw offer
+	case "acceptDraw", "declineDraw":
+		room := player.room
+		if room == nil || room.game == nil {
+			fmt.Println("There is no game for the player")
+			return
+		}
+		playerColor := room.playerColor(player)
+		if playerColor == -1 {
+			return
+		}
+		room.lock()
+		defer room.unlock()
+		if room.game.DrawOffer != 3-playerColor {
+			player.conn.WriteJSON(&ServerError{Error: "There is no draw offer to answer"})
+			return
+		}
+		if clientMessage.Type == "acceptDraw" {
+			room.sendAction(player, AcceptDrawMove(playerColor))
+			return
+		}
+		room.sendAction(player, DeclineDrawMove(playerColor))
+
 	default:
 		fmt.Println("Unknown message type: ", clientMessage.Type)
 	}
 }
 
 // createRoom creates a room
-func (s *Server) createRoom(player *Player) string {
+func (s *Server) createRoom(player *Player, options RoomOptions) string {
 	// generate a random room name
 	rand.Seed(time.Now().UnixNano())
 	roomName := randSeq(5)
@@ -380,11 +643,12 @@ func (s *Server) createRoom(player *Player) string {
 
 	// create a room
 	room := &Room{
-		name:   roomName,
-		status: 1, // 1 = waiting for players
-		game:   nil,
-		white:  player,
-		black:  nil,
+		name:    roomName,
+		status:  1, // 1 = waiting for players
+		game:    nil,
+		white:   player,
+		black:   nil,
+		options: options,
 	}
 	player.room = room
 
@@ -453,6 +717,15 @@ func (s *Server) disconnect(player *Player) {
 
 	// remove player from the room
 	for _, room := range s.rooms {
+		// leaving a game in progress loses it, and the game stops waiting for the player's moves
+		if color := room.playerColor(player); color != -1 && room.status == 2 && room.game != nil {
+			room.lock()
+			if !room.game.IsOver() {
+				room.sendAction(player, AbandonMove(color))
+				room.controller(color).Leave()
+			}
+			room.unlock()
+		}
 		if room.white == player {
 			s.leaveRoom(room, player)
 			s.sendRoomStatus(room)
@@ -519,53 +792,111 @@ func (s *Server) startGame(room *Room) {
 	whitePlayerController := &RemotePlayer{}
 	blackPlayerController := &RemotePlayer{}
 	game := &Game{}
-	game.Init(whitePlayerController, blackPlayerController)
+	if room.options.Chess960 {
+		index := RandomChess960Index()
+		if room.options.Chess960Position != nil {
+			index = *room.options.Chess960Position
+		}
+		// The index was checked when the room was created
+		game.InitChess960(index, whitePlayerController, blackPlayerController)
+	} else {
+		game.Init(whitePlayerController, blackPlayerController)
+	}
+	game.SetTag("Event", "GoChess online game")
+	game.SetTag("Site", "Room "+room.name)
+	game.SetTag("Date", time.Now().Format("2006.01.02"))
+	game.SetTag("White", room.white.name)
+	game.SetTag("Black", room.black.name)
+	if room.options.TimeControl != "" {
+		// The time control was checked when the room was created
+		tc, _ := ParseTimeControl(room.options.TimeControl)
+		game.SetTimeControl(tc)
+	}
 	// init players
 	whitePlayerController.Init(1, &game.Board)
 	blackPlayerController.Init(2, &game.Board)
 
 	// add game to the room
+	room.controllers = [2]*RemotePlayer{whitePlayerController, blackPlayerController}
 	room.game = game
+	game.Subscribe(LogEvents)
+	game.Subscribe(room.listen)
 
 	// game loop
-	for {
-		//fmt.Println("")
-		//fmt.Println(game.Board.FullmoveNumber)
-		//game.Print()
-
-		// send game state to the players
-		gameState := game.Board.Serialize()
-		room.white.conn.WriteJSON(&ClientMessage{
-			Type: "gameState",
-			Data: gameState,
-		})
-		room.black.conn.WriteJSON(&ClientMessage{
-			Type: "gameState",
-			Data: gameState,
-		})
-
-		isEnd := game.Play()
-		if isEnd {
-			break
-		}
+	// The game flags a player whose time runs out while it waits for their move
+	for !game.Play() {
 	}
 
-	// update room status
-	room.status = 3 // 3 = game ended
+	// Kick players from the room, a player who left the game is already gone
+	if room.white != nil {
+		s.leaveRoom(room, room.white)
+	}
+	if room.black != nil {
+		s.leaveRoom(room, room.black)
+	}
+}
+
+// Listen to the events of the room's game and tell the players about them.
+func (r *Room) listen(event GameEvent) {
+	switch event := event.(type) {
+	// send game state to the players whenever a move is expected
+	case TurnEvent:
+		r.broadcast(r.gameMessage("gameState"))
+
+	case DrawOfferEvent:
+		r.send(3-event.Player, &ServerMessage{Type: "drawOffered"})
+
+	case DrawDeclinedEvent:
+		r.send(3-event.Player, &ServerMessage{Type: "drawDeclined"})
 
 	// send game result to the players
-	room.white.conn.WriteJSON(&ClientMessage{
-		Type: "gameResult",
-		Data: game.Board.Serialize(),
-	})
-	room.black.conn.WriteJSON(&ClientMessage{
-		Type: "gameResult",
-		Data: game.Board.Serialize(),
-	})
+	case GameOverEvent:
+		r.status = 3 // 3 = game ended
+		gameResult := r.gameMessage("gameResult")
+		gameResult.PGN = r.game.PGN()
+		gameResult.Reason = event.Result.String()
+		gameResult.Result = &event.Result
+		r.broadcast(gameResult)
+	}
+}
+
+// Send a message to the player of the given color, if they are still in the room.
+func (r *Room) send(color int, message *ServerMessage) {
+	if player := r.player(color); player != nil {
+		player.conn.WriteJSON(message)
+	}
+}
+
+// Send a message to the players in the room.
+func (r *Room) broadcast(message *ServerMessage) {
+	r.send(1, message)
+	r.send(2, message)
+}
 
-	// Kick players from the room
-	s.leaveRoom(room, room.white)
-	s.leaveRoom(room, room.black)
+// Create a message with the state of the room's game: the board, the side on move and the clock.
+func (r *Room) gameMessage(messageType string) *ServerMessage {
+	game := r.game
+	message := &ServerMessage{
+		Type: messageType,
+		Data: game.Board.Serialize(),
+		FEN:  game.Board.ToFEN(),
+		Turn: string(unicode.ToLower(playerLetter(game.Board.State))),
+	}
+	if game.Clock != nil {
+		// The time of a flagged player is sent as 0
+		left := [2]int64{}
+		for i := range left {
+			if remaining := game.Clock.TimeLeft(i + 1); remaining > 0 {
+				left[i] = remaining.Milliseconds()
+			}
+		}
+		message.Clock = &ClockStatus{
+			White:       left[0],
+			Black:       left[1],
+			TimeControl: game.Clock.Control.String(),
+		}
+	}
+	return message
 }
 
 type RoomStatus struct {
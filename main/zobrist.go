@@ -0,0 +1,92 @@
+package main
+
+// Zobrist hashing of positions.
+// Every piece on every square, the side to move, each castling right
+// and the en passant file get a random 64-bit key.
+// The hash of a position is the XOR of the keys of everything in it,
+// so MakeMove can update it by XORing the keys that changed.
+
+var (
+	zobristPieces    [2][6][64]uint64 // indexed by player-1, piece kind and square
+	zobristBlack     uint64           // black to move
+	zobristCastling  [4]uint64        // white queen side, white king side, black queen side, black king side
+	zobristEnPassant [8]uint64        // indexed by the file of the en passant target
+)
+
+func init() {
+	// Use a fixed seed so the hashes are the same on every run.
+	seed := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 {
+		// splitmix64
+		seed += 0x9E3779B97F4A7C15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		return z ^ (z >> 31)
+	}
+
+	for player := 0; player < 2; player++ {
+		for kind := 0; kind < 6; kind++ {
+			for sq := 0; sq < 64; sq++ {
+				zobristPieces[player][kind][sq] = next()
+			}
+		}
+	}
+	zobristBlack = next()
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+}
+
+// Get the key of a piece on a square.
+func pieceHash(player int, pieceType rune, sq int) uint64 {
+	return zobristPieces[player-1][pieceKind(pieceType)][sq]
+}
+
+// Get the keys of everything but the pieces:
+// side to move, castling rights and en passant file.
+// Depends on the pawns, so MakeMove takes it out before moving the pieces and puts it back after.
+func (b *Board) stateHash() uint64 {
+	var hash uint64
+	if b.State == 2 {
+		hash ^= zobristBlack
+	}
+	if b.WhiteQueenSideCastle {
+		hash ^= zobristCastling[0]
+	}
+	if b.WhiteKingSideCastle {
+		hash ^= zobristCastling[1]
+	}
+	if b.BlackQueenSideCastle {
+		hash ^= zobristCastling[2]
+	}
+	if b.BlackKingSideCastle {
+		hash ^= zobristCastling[3]
+	}
+	// The en passant file only counts when a pawn of the side to move can capture,
+	// otherwise the position is the same as without the double push.
+	if target, ok := b.enPassantTarget(b.State); ok {
+		if pawnAttacks[2-b.State][SquareOf(target)]&b.Position.PieceBitboard(b.State, 'P') != 0 {
+			hash ^= zobristEnPassant[target.Y]
+		}
+	}
+	return hash
+}
+
+// Compute the Zobrist hash of the board from scratch.
+// MakeMove keeps Board.Hash up to date, this can be used to validate it.
+func (b *Board) ComputeHash() uint64 {
+	hash := b.stateHash()
+	for player := 1; player <= 2; player++ {
+		for kind, pieceType := range pieceKinds {
+			pieces := b.Position.Pieces[player-1][kind]
+			for pieces != 0 {
+				hash ^= pieceHash(player, pieceType, pieces.PopLSB())
+			}
+		}
+	}
+	return hash
+}